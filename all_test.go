@@ -1,6 +1,8 @@
 package zero
 
 import (
+	"context"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -59,6 +61,30 @@ func TestState_Parse2(t *testing.T) {
 	assert.Equal(t, 520.1314, a.Love)
 }
 
+func TestState_ParseConvert(t *testing.T) {
+	var m struct {
+		I8 int8    `zero:"i8"`
+		U  uint    `zero:"u"`
+		I  int     `zero:"i"`
+		F  float32 `zero:"f"`
+	}
+	ctx := &Ctx{State: State{"i8": int64(100), "u": int64(3), "i": 2.0, "f": int64(1 << 20)}}
+	assert.NoError(t, ctx.Parse(&m))
+	assert.Equal(t, int8(100), m.I8)
+	assert.Equal(t, uint(3), m.U)
+	assert.Equal(t, 2, m.I)
+	for key, v := range map[string]interface{}{
+		"i8": int64(200),       // 溢出
+		"u":  int64(-1),        // 负数到无符号
+		"i":  2.5,              // 截断小数
+		"f":  int64(1<<24 + 1), // float32 精度不足
+	} {
+		ctx := &Ctx{State: State{"i8": int64(1), "u": int64(1), "i": 1.0, "f": int64(1)}}
+		ctx.State[key] = v
+		assert.Error(t, ctx.Parse(&m), key)
+	}
+}
+
 func TestMatcher_Delete(t *testing.T) {
 	OnCommand("").Delete()
 	assert.Empty(t, matcherList)
@@ -70,3 +96,33 @@ func TestFormatMessage_Base64(t *testing.T) {
 	msg := formatMessage([]message.MessageSegment{message.Image(base64Image)})
 	assert.Equal(t, `[{"type":"image","data":{"file":"de8a73807aebf36d8cb25f0f6065d73e.image"}}]`, msg)
 }
+
+func TestCtx_finish(t *testing.T) {
+	e := &Event{PostType: "message"}
+	ctx := &Ctx{Event: e}
+	ctx.ctx, ctx.cancel = context.WithCancel(context.WithValue(basectx, eventContextKey{}, e))
+	inner := ctx.ctx
+	ctx.finish()
+	assert.Error(t, inner.Err()) // 已从 basectx 中移除
+	assert.NoError(t, ctx.GetContext().Err())
+	assert.Equal(t, e, EventFromContext(ctx.GetContext()))
+}
+
+func TestV12ID(t *testing.T) {
+	id := ParseV12ID("user-abc")
+	assert.Equal(t, "user-abc", FormatV12ID(id))
+	m := message.FromV12(message.Message{{Type: "mention", Data: map[string]string{"user_id": "user-abc"}}})
+	assert.Equal(t, strconv.FormatInt(id, 10), m[0].Data["qq"])
+	assert.Equal(t, "user-abc", message.ToV12(m)[0].Data["user_id"])
+
+	c := newIDCache(2)
+	c.store(1, "a")
+	c.store(2, "b")
+	_, _ = c.load(1) // 2 成为最久未使用
+	c.store(3, "c")
+	_, ok := c.load(2)
+	assert.False(t, ok)
+	s, ok := c.load(1)
+	assert.True(t, ok)
+	assert.Equal(t, "a", s)
+}
@@ -50,19 +50,51 @@ func formatMessage(msg interface{}) string {
 }
 
 // CallAction 调用 cqhttp API
+//
+//	OneBot 12 下将映射为对应的动作, 无对应动作时原样调用
 func (ctx *Ctx) CallAction(action string, params Params) APIResponse {
+	rsp, _ := ctx.callAction(action, params)
+	return rsp
+}
+
+// CallActionV12 直接调用 OneBot 12 动作, 不做任何映射
+// https://12.onebot.dev/interface/
+func (ctx *Ctx) CallActionV12(action string, params Params) APIResponse {
+	rsp, _ := ctx.callAPI(APIRequest{
+		Action: action,
+		Params: params,
+	})
+	return rsp
+}
+
+func (ctx *Ctx) callAction(action string, params Params) (APIResponse, error) {
 	req := APIRequest{
 		Action: action,
 		Params: params,
 	}
-	rsp, err := ctx.caller.CallApi(req)
+	if !ctx.v12 {
+		return ctx.callAPI(req)
+	}
+	req, mapped := toV12Request(req)
+	rsp, err := ctx.callAPI(req)
+	if mapped {
+		rsp.Data = fromV12Data(rsp.Data)
+	}
+	return rsp, err
+}
+
+// callAPI 调用 API, 返回值非 0 时返回 *APIError
+func (ctx *Ctx) callAPI(req APIRequest) (APIResponse, error) {
+	rsp, err := ctx.caller.CallApiContext(ctx.GetContext(), req)
 	if err != nil {
-		log.Errorln("[api] 调用", action, "时出现错误: ", err)
+		log.Errorln("[api] 调用", req.Action, "时出现错误: ", err)
+		return rsp, err
 	}
-	if err == nil && rsp.RetCode != 0 {
-		log.Errorln("[api] 调用", action, "时出现错误, 返回值:", rsp.RetCode, ", 信息:", rsp.Msg, "解释:", rsp.Wording)
+	if rsp.RetCode != 0 {
+		log.Errorln("[api] 调用", req.Action, "时出现错误, 返回值:", rsp.RetCode, ", 信息:", rsp.Msg, "解释:", rsp.Wording)
+		return rsp, &APIError{Action: req.Action, RetCode: rsp.RetCode, Msg: rsp.Msg, Wording: rsp.Wording}
 	}
-	return rsp
+	return rsp, nil
 }
 
 // SendGroupMessage 发送群消息
@@ -429,6 +461,31 @@ func (ctx *Ctx) GetVersionInfo() gjson.Result {
 	return ctx.CallAction("get_version_info", Params{}).Data
 }
 
+// HandleQuickOperation 对事件执行快速操作
+// https://github.com/botuniverse/onebot-11/blob/master/api/hidden.md#handle_quick_operation-%E5%AF%B9%E4%BA%8B%E4%BB%B6%E6%89%A7%E8%A1%8C%E5%BF%AB%E9%80%9F%E6%93%8D%E4%BD%9C
+//
+//	operation: reply at_sender delete kick ban ban_duration approve remark reason ...
+//	经 HTTP POST 上报的事件, 在上报返回前调用时快速操作将直接写入响应体
+func (ctx *Ctx) HandleQuickOperation(operation Params) APIResponse {
+	var ev interface{}
+	if ctx.Event != nil && ctx.Event.RawEvent.Raw != "" {
+		ev = json.RawMessage(ctx.Event.RawEvent.Raw)
+	}
+	return ctx.CallAction(".handle_quick_operation", Params{
+		"context":   ev,
+		"operation": operation,
+	})
+}
+
+// QuickReply 快速回复本事件
+// https://github.com/botuniverse/onebot-11/blob/master/event/message.md#%E5%BF%AB%E9%80%9F%E6%93%8D%E4%BD%9C
+func (ctx *Ctx) QuickReply(reply interface{}, atSender bool) APIResponse {
+	return ctx.HandleQuickOperation(Params{
+		"reply":     reply,
+		"at_sender": atSender,
+	})
+}
+
 // Expand API
 
 // SetGroupPortrait 设置群头像
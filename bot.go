@@ -1,6 +1,7 @@
 package zero
 
 import (
+	"context"
 	"encoding/json"
 	"hash/crc64"
 	"runtime/debug"
@@ -27,49 +28,89 @@ type Config struct {
 	Latency        time.Duration `json:"latency"`          // 事件处理延迟 (延迟 latency 再处理事件，在 ring 模式下不可低于 1ms)
 	MaxProcessTime time.Duration `json:"max_process_time"` // 事件最大处理时间 (默认4min)
 	MarkMessage    bool          `json:"mark_message"`     // 自动标记消息为已读
+	MaxMessageLen  int           `json:"max_message_len"`  // Send 单条消息的最大文本字符数 (默认不限制)
+	MaxMessageSegs int           `json:"max_message_segs"` // Send 单条消息的最大消息段数 (默认不限制)
+	LengthPolicy   LengthPolicy  `json:"length_policy"`    // Send 超长消息的处理方式
+	Dedup          *DedupConfig  `json:"dedup"`            // 多账号事件去重 (默认关闭)
 	Driver         []Driver      `json:"-"`                // 通信驱动
 }
 
+// LengthPolicy Send 超长消息的处理方式
+type LengthPolicy uint8
+
+const (
+	// SplitOverLength 在消息段边界拆分为多条消息发送
+	SplitOverLength LengthPolicy = iota
+	// ForwardOverLength 拆分后作为合并转发消息发送, 频道消息仍拆分发送
+	ForwardOverLength
+)
+
 // APICallers 所有的APICaller列表， 通过self-ID映射
 var APICallers callerMap
 
 // APICaller is the interface of CallApi
 type APICaller interface {
 	CallApi(request APIRequest) (APIResponse, error)
+	// CallApiContext 在 ctx 取消时放弃等待并返回 ctx.Err()
+	CallApiContext(ctx context.Context, request APIRequest) (APIResponse, error)
 }
 
 // Driver 与OneBot通信的驱动，使用driver.DefaultWebSocketDriver
 type Driver interface {
 	Connect()
 	Listen(func([]byte, APICaller))
+	// Close 断开连接并使 Listen 返回, 由 Shutdown 调用
+	Close() error
 }
 
 // BotConfig 运行中bot的配置，是Run函数的参数的拷贝
+//
+//	UpdateConfig 会替换本变量, 与其并发读取时请使用 GetConfig
 var BotConfig Config
 
 var (
 	evring    eventRing // evring 事件环
 	isrunning uintptr
+	// configmu 保护 BotConfig
+	configmu sync.RWMutex
+	// linkf 传给 Driver.Listen 的函数, 由 UpdateConfig 用于启动新增的 Driver
+	linkf func([]byte, APICaller)
 )
 
-func runinit(op *Config) {
+// GetConfig 返回当前配置的拷贝
+func GetConfig() Config {
+	configmu.RLock()
+	defer configmu.RUnlock()
+	return BotConfig
+}
+
+func runinit(op *Config) func([]byte, APICaller) {
 	if op.MaxProcessTime == 0 {
 		op.MaxProcessTime = time.Minute * 4
 	}
+	configmu.Lock()
 	BotConfig = *op
-	if op.RingLen == 0 {
-		return
+	linkf = directlink
+	if op.RingLen != 0 {
+		evring = newring(op.RingLen)
+		evring.loop(op.Latency, op.MaxProcessTime, func(b []byte, c APICaller, _ time.Duration) {
+			processEventAsync(b, c, GetConfig().MaxProcessTime)
+		})
+		linkf = evring.processEvent
 	}
-	evring = newring(op.RingLen)
-	evring.loop(op.Latency, op.MaxProcessTime, processEventAsync)
+	f := linkf
+	configmu.Unlock()
+	return f
 }
 
-func (op *Config) directlink(b []byte, c APICaller) {
+// directlink 延迟 Latency 后处理事件, 使用最新的配置
+func directlink(b []byte, c APICaller) {
 	go func() {
-		if op.Latency != 0 {
-			time.Sleep(op.Latency)
+		cfg := GetConfig()
+		if cfg.Latency != 0 {
+			time.Sleep(cfg.Latency)
 		}
-		processEventAsync(b, c, op.MaxProcessTime)
+		processEventAsync(b, c, cfg.MaxProcessTime)
 	}()
 }
 
@@ -78,11 +119,7 @@ func Run(op *Config) {
 	if !atomic.CompareAndSwapUintptr(&isrunning, 0, 1) {
 		log.Warnln("[bot] 已忽略重复调用的 Run")
 	}
-	runinit(op)
-	linkf := op.directlink
-	if op.RingLen != 0 {
-		linkf = evring.processEvent
-	}
+	linkf := runinit(op)
 	for _, driver := range op.Driver {
 		driver.Connect()
 		go driver.Listen(linkf)
@@ -96,11 +133,7 @@ func RunAndBlock(op *Config, preblock func()) {
 	if !atomic.CompareAndSwapUintptr(&isrunning, 0, 1) {
 		log.Warnln("[bot] 已忽略重复调用的 RunAndBlock")
 	}
-	runinit(op)
-	linkf := op.directlink
-	if op.RingLen != 0 {
-		linkf = evring.processEvent
-	}
+	linkf := runinit(op)
 	switch len(op.Driver) {
 	case 0:
 		return
@@ -110,6 +143,7 @@ func RunAndBlock(op *Config, preblock func()) {
 			preblock()
 		}
 		op.Driver[0].Listen(linkf)
+		waitDetached(op.Driver[0])
 	default:
 		i := 0
 		for ; i < len(op.Driver)-1; i++ {
@@ -121,6 +155,7 @@ func RunAndBlock(op *Config, preblock func()) {
 			preblock()
 		}
 		op.Driver[i].Listen(linkf)
+		waitDetached(op.Driver[i])
 	}
 }
 
@@ -135,8 +170,13 @@ type messageLogger struct {
 }
 
 // CallApi 记录被触发的回复消息
-func (m *messageLogger) CallApi(request APIRequest) (rsp APIResponse, err error) {
-	rsp, err = m.caller.CallApi(request)
+func (m *messageLogger) CallApi(request APIRequest) (APIResponse, error) {
+	return m.CallApiContext(context.Background(), request)
+}
+
+// CallApiContext 记录被触发的回复消息
+func (m *messageLogger) CallApiContext(ctx context.Context, request APIRequest) (rsp APIResponse, err error) {
+	rsp, err = m.caller.CallApiContext(ctx, request)
 	if err != nil {
 		return
 	}
@@ -162,11 +202,32 @@ func GetTriggeredMessages(id message.MessageID) []message.MessageID {
 	return triggeredMessages.Get(id.ID())
 }
 
+// EventFinisher 可由 APICaller 实现, 在通过它上报的事件处理结束或被丢弃后调用
+//
+//	如 HTTP POST 驱动据此在处理结束后立即响应上报请求
+type EventFinisher interface {
+	EventFinished()
+}
+
+func finishEvent(caller APICaller) {
+	if f, ok := caller.(EventFinisher); ok {
+		f.EventFinished()
+	}
+}
+
 // processEventAsync 从池中处理事件, 异步调用匹配 mather
 func processEventAsync(response []byte, caller APICaller, maxwait time.Duration) {
+	if !track() { // 已关闭, 不再接收事件
+		finishEvent(caller)
+		return
+	}
 	var event Event
+	rawEvent := gjson.Parse(helper.BytesToString(response))
+	if isV12Event(rawEvent) {
+		response, event.Self = normalizeV12Event(rawEvent)
+	}
 	_ = json.Unmarshal(response, &event)
-	event.RawEvent = gjson.Parse(helper.BytesToString(response))
+	event.RawEvent = rawEvent
 	var msgid message.MessageID
 	messageID, err := strconv.ParseInt(helper.BytesToString(event.RawMessageID), 10, 64)
 	if err == nil {
@@ -177,26 +238,17 @@ func processEventAsync(response []byte, caller APICaller, maxwait time.Duration)
 		// MessageID 填为 string
 		event.MessageID, _ = strconv.Unquote(helper.BytesToString(event.RawMessageID))
 		// 伪造 GroupID
-		crc := crc64.New(crc64.MakeTable(crc64.ISO))
-		crc.Write(helper.StringToBytes(event.GuildID))
-		crc.Write(helper.StringToBytes(event.ChannelID))
-		r := int64(crc.Sum64() & 0x7fff_ffff_ffff_ffff) // 确保为正数
-		if r <= 0xffff_ffff {
-			r |= 0x1_0000_0000 // 确保不与正常号码重叠
-		}
-		event.GroupID = r
+		event.GroupID = crcID(event.GuildID, event.ChannelID)
 		// 伪造 UserID
-		crc.Reset()
-		crc.Write(helper.StringToBytes(event.TinyID))
-		r = int64(crc.Sum64() & 0x7fff_ffff_ffff_ffff) // 确保为正数
-		if r <= 0xffff_ffff {
-			r |= 0x1_0000_0000 // 确保不与正常号码重叠
-		}
-		event.UserID = r
+		event.UserID = crcID(event.TinyID)
 		if event.Sender != nil {
-			event.Sender.ID = r
+			event.Sender.ID = event.UserID
 		}
 		msgid = message.NewMessageIDFromString(event.MessageID.(string))
+	} else if event.Self != nil && len(event.RawMessageID) > 0 {
+		// OneBot 12 的 message_id 为 string
+		event.MessageID, _ = strconv.Unquote(helper.BytesToString(event.RawMessageID))
+		msgid = message.NewMessageIDFromString(event.MessageID.(string))
 	}
 
 	switch event.PostType { // process DetailType
@@ -207,23 +259,48 @@ func processEventAsync(response []byte, caller APICaller, maxwait time.Duration)
 		preprocessNoticeEvent(&event)
 	case "request":
 		event.DetailType = event.RequestType
+	case "meta_event":
+		event.DetailType = event.MetaEventType
 	}
 	if event.PostType == "message" {
 		preprocessMessageEvent(&event)
 	}
+	routes.observe(&event)
+	if isDuplicate(&event) {
+		inflight.Done()
+		finishEvent(caller)
+		return
+	}
+	if observer != nil {
+		observer.ObserveEvent(&event)
+	}
 	ctx := &Ctx{
 		Event:  &event,
 		State:  State{},
-		caller: &messageLogger{msgid: msgid, caller: caller},
+		caller: &messageLogger{msgid: msgid, caller: intercept(caller)},
+		v12:    event.Self != nil || isV12Caller(caller),
 	}
-	matcherLock.Lock()
-	if hasMatcherListChanged {
-		matcherListForRanging = make([]IMatcher, len(matcherList))
-		copy(matcherListForRanging, matcherList)
-		hasMatcherListChanged = false
+	ctx.ctx, ctx.cancel = context.WithCancel(context.WithValue(basectx, eventContextKey{}, &event))
+	matchers := currentIndex().candidates(ctx)
+	go func() {
+		defer inflight.Done()
+		defer finishEvent(caller)
+		defer ctx.finish() // 释放 basectx 下的子 context
+		match(ctx, matchers, maxwait)
+	}()
+}
+
+// crcID 将 string 类型的 ID 映射为不与正常号码重叠的正数
+func crcID(s ...string) int64 {
+	crc := crc64.New(crc64.MakeTable(crc64.ISO))
+	for _, x := range s {
+		crc.Write(helper.StringToBytes(x))
+	}
+	r := int64(crc.Sum64() & 0x7fff_ffff_ffff_ffff) // 确保为正数
+	if r <= 0xffff_ffff {
+		r |= 0x1_0000_0000 // 确保不与正常号码重叠
 	}
-	matcherLock.Unlock()
-	go match(ctx, matcherListForRanging, maxwait)
+	return r
 }
 
 func gorule(ctx Context, rule Rule) <-chan bool {
@@ -256,71 +333,104 @@ func gohandler(ctx Context, handler Handler) <-chan struct{} {
 }
 
 func processMatchers(ctx Context, matchers []IMatcher, t *time.Timer) {
-	for _, matcher := range matchers {
-		if !matcher.GetType()(ctx) { // 不匹配直接跳过
-			continue
+	if observer != nil {
+		defer func(start time.Time) {
+			observer.ObserveMatchers(ctx.GetEvent(), time.Since(start))
+		}(time.Now())
+	}
+	for i := 0; i < len(matchers); i++ {
+		if processMatcher(ctx, matchers[i], t) {
+			return
 		}
-		for k := range ctx.GetState() { // Clear State
-			delete(ctx.GetState(), k)
+		if c, ok := ctx.(*Ctx); ok {
+			matchers = c.fallback(matchers, i)
 		}
-		// copy matcher
-		m := matcher.(*Matcher).copy() // todo: fix possible panic
-		ctx.setMatcher(m)
+	}
+}
 
-		// pre handler
-		if eng := m.GetEngine(); eng != nil {
-			if processEnginePreHandler(ctx, eng, t) { // true 退出循环
-				return
-			}
-		}
-		// rules
-		if processRules(ctx, m.GetRules(), t) {
-			return
-		}
-		// mid handler
-		if eng := m.GetEngine(); eng != nil {
-			if processEngineMidHandler(ctx, eng, t) { // true 退出循环
-				return
-			}
+// processMatcher 匹配并处理 matcher, 返回是否退出上层循环
+func processMatcher(ctx Context, matcher IMatcher, t *time.Timer) bool {
+	if !matcher.GetType()(ctx) { // 不匹配直接跳过
+		return false
+	}
+	for k := range ctx.GetState() { // Clear State
+		delete(ctx.GetState(), k)
+	}
+	// copy matcher
+	m := matcher.(*Matcher).copy() // todo: fix possible panic
+	ctx.setMatcher(m)
+
+	// pre handler
+	if eng := m.GetEngine(); eng != nil {
+		pass, exit := processEnginePreHandler(ctx, eng, t)
+		if exit || !pass && m.GetBreak() { // true 退出循环
+			return true
 		}
-		// handler
-		if processMatcherHandler(ctx, t) { // true 退出循环
-			return
+		if !pass {
+			return false
 		}
-		if m.GetTemp() { // 临时 Matcher 删除
-			m.Delete()
+	}
+	// rules
+	pass, exit := processRules(ctx, m.GetRules(), t)
+	if exit || !pass && m.GetBreak() {
+		return true
+	}
+	if !pass {
+		return false
+	}
+	// mid handler
+	if eng := m.GetEngine(); eng != nil {
+		pass, exit := processEngineMidHandler(ctx, eng, t)
+		if exit || !pass && m.GetBreak() { // true 退出循环
+			return true
 		}
-		// post handler
-		if eng := m.GetEngine(); eng != nil {
-			if processEnginePostHandler(ctx, eng, t) { // true 退出循环
-				return
-			}
+		if !pass {
+			return false
 		}
-		if m.GetBlock() { // 阻断后续
-			return
+	}
+	// handler
+	if processMatcherHandler(ctx, t) { // true 退出循环
+		return true
+	}
+	if m.GetTemp() { // 临时 Matcher 删除, m 为副本
+		matcher.Delete()
+	}
+	// post handler
+	if eng := m.GetEngine(); eng != nil {
+		if processEnginePostHandler(ctx, eng, t) { // true 退出循环
+			return true
 		}
 	}
+	return m.GetBlock() // 阻断后续
 }
 
-func processRule(ctx Context, rule Rule, t *time.Timer, logStr string) bool {
+// processRule 返回 rule 是否通过, 以及是否超时退出上层循环
+func processRule(ctx Context, rule Rule, t *time.Timer, logStr string) (pass, exit bool) {
+	if isSyncRule(rule) {
+		return runSyncRule(ctx, rule), false
+	}
 	c := gorule(ctx, rule)
 	for {
 		select {
 		case ok := <-c:
 			if !ok {
-				return ctx.getMatcher().GetBreak()
+				return false, false
 			}
 		case <-t.C:
 			if ctx.getMatcher().GetNoTimeout() {
-				t.Reset(BotConfig.MaxProcessTime)
+				t.Reset(GetConfig().MaxProcessTime)
 				continue
 			}
 			log.Warnf("[bot] %v 处理达到最大时延, 退出", logStr)
-			return true
+			ctx.cancelCalls() // 取消未完成的 API 调用
+			if observer != nil {
+				observer.ObserveTimeout(ctx.getMatcher(), logStr)
+			}
+			return false, true
 		}
 		break
 	}
-	return false
+	return true, false
 }
 
 func processHandler(ctx Context, handler Handler, t *time.Timer, logStr string) bool {
@@ -330,10 +440,14 @@ func processHandler(ctx Context, handler Handler, t *time.Timer, logStr string)
 		case <-c:
 		case <-t.C:
 			if ctx.getMatcher().GetNoTimeout() {
-				t.Reset(BotConfig.MaxProcessTime)
+				t.Reset(GetConfig().MaxProcessTime)
 				continue
 			}
 			log.Warnf("[bot] %v 处理达到最大时延, 退出", logStr)
+			ctx.cancelCalls() // 取消未完成的 API 调用
+			if observer != nil {
+				observer.ObserveTimeout(ctx.getMatcher(), logStr)
+			}
 			return true
 		}
 		break
@@ -341,34 +455,32 @@ func processHandler(ctx Context, handler Handler, t *time.Timer, logStr string)
 	return false
 }
 
-func processEnginePreHandler(ctx Context, engine IEngine, t *time.Timer) bool { // 返回是否退出上层循环
-	for _, handler := range engine.getPreHandler() {
-		if processRule(ctx, handler, t, "preHandler") {
-			return true
-		}
-	}
-	return false
+func processEnginePreHandler(ctx Context, engine IEngine, t *time.Timer) (pass, exit bool) { // 返回是否通过, 是否退出上层循环
+	defer observeStage(ctx, "preHandler", time.Now())
+	return processRuleList(ctx, engine.getPreHandler(), t, "preHandler")
 }
 
-func processRules(ctx Context, rules []Rule, t *time.Timer) bool {
-	for _, rule := range rules {
-		if processRule(ctx, rule, t, "rule") {
-			return true
-		}
-	}
-	return false
+func processRules(ctx Context, rules []Rule, t *time.Timer) (pass, exit bool) {
+	defer observeStage(ctx, "rule", time.Now())
+	return processRuleList(ctx, rules, t, "rule")
 }
 
-func processEngineMidHandler(ctx Context, engine IEngine, t *time.Timer) bool {
-	for _, handler := range engine.getMidHandler() {
-		if processRule(ctx, handler, t, "midHandler") {
-			return true
+func processEngineMidHandler(ctx Context, engine IEngine, t *time.Timer) (pass, exit bool) {
+	defer observeStage(ctx, "midHandler", time.Now())
+	return processRuleList(ctx, engine.getMidHandler(), t, "midHandler")
+}
+
+func processRuleList(ctx Context, rules []Rule, t *time.Timer, logStr string) (pass, exit bool) {
+	for _, rule := range rules {
+		if pass, exit = processRule(ctx, rule, t, logStr); !pass {
+			return
 		}
 	}
-	return false
+	return true, false
 }
 
 func processMatcherHandler(ctx Context, t *time.Timer) bool {
+	defer observeStage(ctx, "handler", time.Now())
 	if h := ctx.getMatcher().GetHandler(); h != nil {
 		if processHandler(ctx, h, t, "handler") {
 			return true
@@ -378,6 +490,7 @@ func processMatcherHandler(ctx Context, t *time.Timer) bool {
 }
 
 func processEnginePostHandler(ctx Context, engine IEngine, t *time.Timer) bool {
+	defer observeStage(ctx, "postHandler", time.Now())
 	for _, handler := range engine.getPostHandler() {
 		if processHandler(ctx, handler, t, "postHandler") {
 			return true
@@ -388,7 +501,7 @@ func processEnginePostHandler(ctx Context, engine IEngine, t *time.Timer) bool {
 
 // match 匹配规则，处理事件
 func match(ctx Context, matchers []IMatcher, maxwait time.Duration) {
-	if BotConfig.MarkMessage && ctx.GetEvent().MessageID != nil {
+	if GetConfig().MarkMessage && ctx.GetEvent().MessageID != nil {
 		ctx.MarkThisMessageAsRead()
 	}
 	t := time.NewTimer(maxwait)
@@ -414,7 +527,7 @@ func processAtEvent(e *Event) {
 	first := e.Message[0]
 	first.Data["text"] = strings.TrimLeft(first.Data["text"], " ") // Trim!
 	text := first.Data["text"]
-	for _, nickname := range BotConfig.NickName {
+	for _, nickname := range GetConfig().NickName {
 		if strings.HasPrefix(text, nickname) {
 			e.IsToMe = true
 			first.Data["text"] = text[len(nickname):]
@@ -458,7 +571,16 @@ func GetBot(id int64) Context {
 	if !ok {
 		return nil
 	}
-	return &Ctx{caller: caller}
+	return botCtx(id, caller)
+}
+
+// botCtx 非事件触发的 Ctx, 其 API 调用可通过 SelfIDFromContext 获取账号
+func botCtx(id int64, caller APICaller) *Ctx {
+	return &Ctx{
+		caller: intercept(caller),
+		v12:    isV12Caller(caller),
+		ctx:    context.WithValue(context.Background(), selfContextKey{}, id),
+	}
 }
 
 // RangeBot 遍历所有bot (Ctx)实例
@@ -466,7 +588,7 @@ func GetBot(id int64) Context {
 // 单次操作返回 true 则继续遍历，否则退出
 func RangeBot(iter func(id int64, ctx Context) bool) {
 	APICallers.Range(func(key int64, value APICaller) bool {
-		return iter(key, &Ctx{caller: value})
+		return iter(key, botCtx(key, value))
 	})
 }
 
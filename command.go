@@ -0,0 +1,295 @@
+package zero
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// ArgType 命令参数的类型
+//
+//	Parse 的输入为一个参数: 文本参数为 text 消息段, 其余为原消息段, 如 at
+type ArgType struct {
+	Name  string // 用法中显示的类型名
+	Parse func(seg message.MessageSegment) (interface{}, error)
+}
+
+// 内置的参数类型, 解析结果分别为 string int64 time.Duration int64(QQ号) string
+var (
+	ArgString = ArgType{Name: "文本", Parse: func(seg message.MessageSegment) (interface{}, error) {
+		if seg.Type != "text" {
+			return nil, errors.New("应为文本")
+		}
+		return seg.Data["text"], nil
+	}}
+	ArgInt = ArgType{Name: "整数", Parse: func(seg message.MessageSegment) (interface{}, error) {
+		if seg.Type != "text" {
+			return nil, errors.New("应为整数")
+		}
+		n, err := strconv.ParseInt(seg.Data["text"], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q 不是整数", seg.Data["text"])
+		}
+		return n, nil
+	}}
+	ArgDuration = ArgType{Name: "时长", Parse: func(seg message.MessageSegment) (interface{}, error) {
+		if seg.Type != "text" {
+			return nil, errors.New("应为时长")
+		}
+		d, err := time.ParseDuration(seg.Data["text"])
+		if err != nil {
+			return nil, fmt.Errorf("%q 不是时长, 如 10m 1h30m", seg.Data["text"])
+		}
+		return d, nil
+	}}
+	// ArgAt 接受 at 消息段, 也接受 123456 或 @123456 形式的 QQ 号
+	ArgAt = ArgType{Name: "@", Parse: func(seg message.MessageSegment) (interface{}, error) {
+		var s string
+		switch seg.Type {
+		case "at":
+			s = seg.Data["qq"]
+		case "text":
+			s = strings.TrimPrefix(seg.Data["text"], "@")
+		default:
+			return nil, errors.New("应为 @某人")
+		}
+		uid, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || uid <= 0 {
+			return nil, errors.New("应为 @某人")
+		}
+		return uid, nil
+	}}
+)
+
+// ArgEnum 取值为 values 之一的文本参数
+func ArgEnum(values ...string) ArgType {
+	return ArgType{Name: strings.Join(values, "|"), Parse: func(seg message.MessageSegment) (interface{}, error) {
+		if seg.Type == "text" {
+			for _, v := range values {
+				if seg.Data["text"] == v {
+					return v, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("应为 %s 之一", strings.Join(values, " "))
+	}}
+}
+
+type commandArg struct {
+	name string
+	typ  ArgType
+}
+
+// CommandNode 命令树的节点, 由 Engine.Command 创建
+//
+//	匹配时依次解析节点的参数, 其后的首个文本若为子命令名则进入子命令, 解析结果按参数名存入 State,
+//	可通过 Ctx.Parse 读取; State["command"] 为以空格分隔的命令路径, 如 "group ban".
+//	解析失败时回复错误与生成的用法.
+type CommandNode struct {
+	name    string
+	help    string
+	parent  *CommandNode
+	subs    []*CommandNode
+	args    []commandArg
+	handler Handler
+	matcher IMatcher // 根节点注册的 Matcher
+}
+
+// Command 命令树触发器(默认Engine)
+func Command(name string, rules ...Rule) *CommandNode {
+	return defaultEngine.Command(name, rules...)
+}
+
+// Command 命令树触发器, 返回根命令节点
+//
+//	如 e.Command("group").Sub("ban", handler).Arg("user", ArgAt).Arg("minutes", ArgDuration)
+//	将在收到 "/group ban @某人 10m" 时调用 handler
+func (e *Engine) Command(name string, rules ...Rule) *CommandNode {
+	c := &CommandNode{name: name}
+	matcher := &Matcher{
+		Type:    Type("message"),
+		Rules:   append([]Rule{c.rule()}, rules...),
+		Engine:  e,
+		Handler: c.handle,
+		trigger: trigger{kind: TriggerCommand, typ: "message", literals: []string{name}},
+	}
+	e.matchers = append(e.matchers, matcher)
+	c.matcher = StoreMatcher(matcher)
+	return c
+}
+
+// Sub 添加子命令, 返回子命令节点
+func (c *CommandNode) Sub(name string, handler Handler) *CommandNode {
+	sub := &CommandNode{name: name, parent: c, handler: handler}
+	c.subs = append(c.subs, sub)
+	return sub
+}
+
+// Arg 添加位置参数, 参数在子命令名之前解析
+func (c *CommandNode) Arg(name string, typ ArgType) *CommandNode {
+	c.args = append(c.args, commandArg{name: name, typ: typ})
+	return c
+}
+
+// Handle 设置该节点的处理函数
+func (c *CommandNode) Handle(handler Handler) *CommandNode {
+	c.handler = handler
+	return c
+}
+
+// Help 设置用法中显示的说明
+func (c *CommandNode) Help(help string) *CommandNode {
+	c.help = help
+	return c
+}
+
+// Matcher 返回根命令注册的 Matcher, 可用于设置优先级等
+func (c *CommandNode) Matcher() IMatcher {
+	for c.parent != nil {
+		c = c.parent
+	}
+	return c.matcher
+}
+
+// Path 以空格分隔的命令路径, 不含 CommandPrefix
+func (c *CommandNode) Path() string {
+	if c.parent == nil {
+		return c.name
+	}
+	return c.parent.Path() + " " + c.name
+}
+
+// Usage 生成该节点及其子命令的用法
+func (c *CommandNode) Usage() string {
+	sb := strings.Builder{}
+	sb.WriteString("用法:")
+	c.usage(&sb, GetConfig().CommandPrefix+c.Path())
+	return sb.String()
+}
+
+func (c *CommandNode) usage(sb *strings.Builder, line string) {
+	for _, a := range c.args {
+		line += " <" + a.name + ":" + a.typ.Name + ">"
+	}
+	if c.handler != nil || len(c.subs) == 0 {
+		sb.WriteString("\n")
+		sb.WriteString(line)
+		if c.help != "" {
+			sb.WriteString("  ")
+			sb.WriteString(c.help)
+		}
+	}
+	for _, sub := range c.subs {
+		sub.usage(sb, line+" "+sub.name)
+	}
+}
+
+// rule 首段文本以 CommandPrefix + name 开头, 且其后为空白或结束
+func (c *CommandNode) rule() Rule {
+	return func(ctx Context) bool {
+		rest, ok := c.trim(ctx.GetEvent().Message)
+		if !ok || (rest != "" && !isSpace(rune(rest[0]))) {
+			return false
+		}
+		ctx.GetState()["command"] = c.name
+		return true
+	}
+}
+
+func (c *CommandNode) trim(msg message.Message) (string, bool) {
+	if len(msg) == 0 || msg[0].Type != "text" {
+		return "", false
+	}
+	cmd := GetConfig().CommandPrefix + c.name
+	text := msg[0].Data["text"]
+	if !strings.HasPrefix(text, cmd) {
+		return "", false
+	}
+	return text[len(cmd):], true
+}
+
+func (c *CommandNode) handle(ctx Context) {
+	msg := ctx.GetEvent().Message
+	rest, _ := c.trim(msg)
+	node, err := c.resolve(commandTokens(rest, msg[1:]), ctx.GetState())
+	if err != nil {
+		ctx.Send(message.Text(err, "\n", node.Usage()))
+		return
+	}
+	ctx.GetState()["command"] = node.Path()
+	node.handler(ctx)
+}
+
+// resolve 解析参数与子命令, 返回最终到达的节点
+func (c *CommandNode) resolve(toks []message.MessageSegment, state State) (*CommandNode, error) {
+	node := c
+	for {
+		for _, a := range node.args {
+			if len(toks) == 0 {
+				return node, fmt.Errorf("缺少参数 %s", a.name)
+			}
+			v, err := a.typ.Parse(toks[0])
+			if err != nil {
+				return node, fmt.Errorf("参数 %s: %w", a.name, err)
+			}
+			state[a.name] = v
+			toks = toks[1:]
+		}
+		if len(toks) == 0 {
+			if node.handler == nil {
+				return node, errors.New("缺少子命令")
+			}
+			return node, nil
+		}
+		sub := node.sub(toks[0])
+		if sub == nil {
+			if len(node.subs) > 0 {
+				return node, fmt.Errorf("未知子命令 %s", tokenString(toks[0]))
+			}
+			return node, fmt.Errorf("多余的参数 %s", tokenString(toks[0]))
+		}
+		node, toks = sub, toks[1:]
+	}
+}
+
+func (c *CommandNode) sub(tok message.MessageSegment) *CommandNode {
+	if tok.Type != "text" {
+		return nil
+	}
+	for _, sub := range c.subs {
+		if sub.name == tok.Data["text"] {
+			return sub
+		}
+	}
+	return nil
+}
+
+func tokenString(tok message.MessageSegment) string {
+	if tok.Type == "text" {
+		return tok.Data["text"]
+	}
+	return tok.CQCode()
+}
+
+// commandTokens 将文本按 ParseShell 拆分, 非文本消息段各自作为一个参数
+func commandTokens(first string, rest message.Message) []message.MessageSegment {
+	var toks []message.MessageSegment
+	text := func(s string) {
+		for _, arg := range ParseShell(s) {
+			toks = append(toks, message.Text(arg))
+		}
+	}
+	text(first)
+	for _, seg := range rest {
+		if seg.Type == "text" {
+			text(seg.Data["text"])
+			continue
+		}
+		toks = append(toks, seg)
+	}
+	return toks
+}
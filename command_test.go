@@ -0,0 +1,72 @@
+package zero
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+func TestCommandTree(t *testing.T) {
+	oldPrefix := BotConfig.CommandPrefix
+	BotConfig.CommandPrefix = "/"
+	defer func() { BotConfig.CommandPrefix = oldPrefix }()
+
+	e := New()
+	defer e.Delete()
+	var ban struct {
+		User    int64         `zero:"user"`
+		Minutes time.Duration `zero:"minutes"`
+		Command string        `zero:"command"`
+	}
+	var mode struct {
+		Mode  string `zero:"mode"`
+		Times int    `zero:"times"` // int64 按字段类型转换
+	}
+	root := e.Command("group")
+	root.Sub("ban", func(ctx Context) { assert.NoError(t, ctx.Parse(&ban)) }).
+		Arg("user", ArgAt).Arg("minutes", ArgDuration).Help("禁言")
+	root.Sub("mode", func(ctx Context) { assert.NoError(t, ctx.Parse(&mode)) }).
+		Arg("mode", ArgEnum("on", "off")).Arg("times", ArgInt)
+
+	ctx := &Ctx{Event: &Event{PostType: "message", Message: message.Message{
+		message.Text("/group ban "), message.At(123), message.Text(" 10m"),
+	}}, State: State{}}
+	assert.True(t, root.rule()(ctx))
+	root.handle(ctx)
+	assert.Equal(t, int64(123), ban.User)
+	assert.Equal(t, 10*time.Minute, ban.Minutes)
+	assert.Equal(t, "group ban", ban.Command)
+
+	ctx = messageCtx(`/group mode "on" 3`)
+	assert.True(t, root.rule()(ctx))
+	root.handle(ctx)
+	assert.Equal(t, "on", mode.Mode)
+	assert.Equal(t, 3, mode.Times)
+
+	assert.False(t, root.rule()(messageCtx("/groups ban")))
+
+	for text, want := range map[string]string{
+		"":              "缺少子命令",
+		"kick":          "未知子命令 kick",
+		"ban 123":       "缺少参数 minutes",
+		"ban abc 10m":   "参数 user: 应为 @某人",
+		"ban 123 10":    `参数 minutes: "10" 不是时长, 如 10m 1h30m`,
+		"mode maybe 1":  "参数 mode: 应为 on off 之一",
+		"mode on 1 two": "多余的参数 two",
+	} {
+		_, err := root.resolve(commandTokens(text, nil), State{})
+		assert.EqualError(t, err, want, text)
+	}
+
+	assert.Equal(t, "用法:\n/group ban <user:@> <minutes:时长>  禁言\n/group mode <mode:on|off> <times:整数>", root.Usage())
+	assert.True(t, isSyncRule(root.rule()))
+	for _, info := range ListMatchers() {
+		if info.Matcher == root.Matcher() {
+			assert.Equal(t, TriggerCommand, info.Kind)
+			assert.Equal(t, []string{"group"}, info.Literals)
+		}
+	}
+}
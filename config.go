@@ -0,0 +1,596 @@
+package zero
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigError 配置文件中某一字段的错误
+type ConfigError struct {
+	File  string // 配置文件路径
+	Field string // 字段位置, 如 driver[1].url
+	Err   error
+}
+
+// Error 实现 error
+func (e *ConfigError) Error() string {
+	switch {
+	case e.File == "":
+		return e.Field + ": " + e.Err.Error()
+	case e.Field == "":
+		return e.File + ": " + e.Err.Error()
+	}
+	return e.File + ": " + e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap 返回原始错误
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// fieldError 未填写 File 的 ConfigError, 由 LoadConfig 补全
+func fieldError(field string, format string, a ...interface{}) error {
+	return &ConfigError{Field: field, Err: fmt.Errorf(format, a...)}
+}
+
+// DriverFactory 根据配置文件中的声明创建 Driver
+type DriverFactory func(c DriverConfig) (Driver, error)
+
+var (
+	driverFactories   = map[string]DriverFactory{}
+	driverFactoriesMu sync.RWMutex
+	// loadedDrivers driver 项 (替换环境变量后) 到所创建 Driver 的映射
+	loadedDrivers   = map[string]Driver{}
+	loadedDriversMu sync.Mutex
+)
+
+// RegisterDriver 注册配置文件中 type 为 typ 的驱动, 由 driver 包在 init 中调用
+//
+//	使用 LoadConfig 前需导入提供该驱动的包, 如 github.com/wdvxdr1123/ZeroBot/driver
+func RegisterDriver(typ string, f DriverFactory) {
+	driverFactoriesMu.Lock()
+	driverFactories[typ] = f
+	driverFactoriesMu.Unlock()
+}
+
+// DriverConfig 配置文件中 driver 列表的一项
+type DriverConfig struct {
+	Type  string // 驱动类型
+	Field string // 在配置文件中的位置, 如 driver[1]
+	raw   map[string]interface{}
+}
+
+// Decode 将该项解析到 v, 忽略 type 字段, v 的字段使用 json tag 命名
+func (c DriverConfig) Decode(v interface{}) error {
+	m := make(map[string]interface{}, len(c.raw))
+	for k, x := range c.raw {
+		if k != "type" {
+			m[k] = x
+		}
+	}
+	return decodeValue(c.Field, m, reflect.ValueOf(v).Elem())
+}
+
+// Errorf 返回指向该项 field 字段的错误
+func (c DriverConfig) Errorf(field, format string, a ...interface{}) error {
+	return fieldError(c.Field+"."+field, format, a...)
+}
+
+// LoadConfig 读取 YAML JSON 或 TOML 配置文件, 格式由扩展名决定
+//
+//	字符串中的 ${VAR} 与 ${VAR:-default} 将替换为环境变量, $$ 表示 $
+//	时长可写作 "4m" 或 "1.5s", 整数视为秒数
+//	与正在运行的 Driver 配置 (替换环境变量后) 相同的 driver 项返回该 Driver, 以便 UpdateConfig 沿用
+//	驱动在 driver 列表中声明, 如
+//
+//	driver:
+//	  - type: ws
+//	    url: ws://127.0.0.1:6700
+//	    access_token: ${ONEBOT_TOKEN}
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseConfig(data, strings.TrimPrefix(filepath.Ext(path), "."))
+	var cerr *ConfigError
+	if errors.As(err, &cerr) {
+		cerr.File = path
+	} else if err != nil {
+		err = &ConfigError{File: path, Err: err}
+	}
+	return c, err
+}
+
+// ParseConfig 解析 format 格式的配置, format 为 yaml yml json 或 toml
+func ParseConfig(data []byte, format string) (*Config, error) {
+	var tree map[string]interface{}
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&tree); err != nil {
+			return nil, err
+		}
+	case "toml":
+		if err := toml.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format %q", format)
+	}
+	c := new(Config)
+	drivers := tree["driver"]
+	delete(tree, "driver")
+	if err := decodeValue("", tree, reflect.ValueOf(c).Elem()); err != nil {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if drivers == nil {
+		return c, nil
+	}
+	list := reflect.ValueOf(drivers)
+	if list.Kind() != reflect.Slice {
+		return nil, fieldError("driver", "expected a list, got %s", typeName(drivers))
+	}
+	for i := 0; i < list.Len(); i++ {
+		d, err := newDriver("driver["+strconv.Itoa(i)+"]", list.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		c.Driver = append(c.Driver, d)
+	}
+	return c, nil
+}
+
+// newDriver 根据 type 调用对应的 DriverFactory
+func newDriver(field string, x interface{}) (Driver, error) {
+	raw, ok := toStringMap(x)
+	if !ok {
+		return nil, fieldError(field, "expected a table, got %s", typeName(x))
+	}
+	typ, _ := raw["type"].(string)
+	if typ == "" {
+		return nil, fieldError(field+".type", "required")
+	}
+	driverFactoriesMu.RLock()
+	f, ok := driverFactories[typ]
+	types := make([]string, 0, len(driverFactories))
+	for k := range driverFactories {
+		types = append(types, k)
+	}
+	driverFactoriesMu.RUnlock()
+	if !ok {
+		sort.Strings(types)
+		return nil, fieldError(field+".type", "unknown driver type %q (registered: %s)", typ, strings.Join(types, ", "))
+	}
+	expanded, err := expandTree(field, raw)
+	if err != nil {
+		return nil, err
+	}
+	key, err := json.Marshal(expanded)
+	if err != nil {
+		return nil, fieldError(field, "%v", err)
+	}
+	running := GetConfig().Driver // 不可持有 loadedDriversMu 获取 configmu, 参见 diffDrivers
+	loadedDriversMu.Lock()
+	defer loadedDriversMu.Unlock()
+	if d, ok := loadedDrivers[string(key)]; ok && containsDriver(running, d) {
+		return d, nil // 配置未变化, 沿用正在运行的 Driver
+	}
+	d, err := f(DriverConfig{Type: typ, Field: field, raw: raw})
+	if err == nil {
+		loadedDrivers[string(key)] = d
+	}
+	return d, err
+}
+
+// forgetDriver 从 loadedDrivers 中删除被移除的 Driver
+func forgetDriver(d Driver) {
+	loadedDriversMu.Lock()
+	defer loadedDriversMu.Unlock()
+	for k, x := range loadedDrivers {
+		if sameDriver(x, d) {
+			delete(loadedDrivers, k)
+		}
+	}
+}
+
+// validate 检查字段取值
+func (c *Config) validate() error {
+	switch {
+	case c.MaxProcessTime < 0:
+		return fieldError("max_process_time", "must not be negative")
+	case c.Latency < 0:
+		return fieldError("latency", "must not be negative")
+	case c.MaxMessageLen < 0:
+		return fieldError("max_message_len", "must not be negative")
+	case c.MaxMessageSegs < 0:
+		return fieldError("max_message_segs", "must not be negative")
+	case c.RingLen > 0 && c.Latency > 0 && c.Latency < time.Millisecond:
+		return fieldError("latency", "must be at least 1ms when ring_len is set")
+	case c.Dedup != nil && c.Dedup.Window < 0:
+		return fieldError("dedup.window", "must not be negative")
+	}
+	return nil
+}
+
+// UnmarshalText 解析 split 或 forward
+func (p *LengthPolicy) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "split", "":
+		*p = SplitOverLength
+	case "forward":
+		*p = ForwardOverLength
+	default:
+		return fmt.Errorf("unknown length policy %q, expected split or forward", text)
+	}
+	return nil
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// decodeValue 将 YAML JSON TOML 解析出的 in 写入 out, 出错时返回指向 field 的 ConfigError
+func decodeValue(field string, in interface{}, out reflect.Value) error {
+	if s, ok := in.(string); ok {
+		expanded, err := expandEnv(s)
+		if err != nil {
+			return fieldError(field, "%v", err)
+		}
+		in = expanded
+	}
+	if in == nil {
+		return nil
+	}
+	if out.Kind() == reflect.Ptr {
+		if out.IsNil() {
+			out.Set(reflect.New(out.Type().Elem()))
+		}
+		return decodeValue(field, in, out.Elem())
+	}
+	if out.Type() == durationType {
+		return decodeDuration(field, in, out)
+	}
+	if out.CanAddr() && out.Addr().Type().Implements(textUnmarshalerType) {
+		if s, ok := in.(string); ok {
+			if err := out.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+				return fieldError(field, "%v", err)
+			}
+			return nil
+		}
+	}
+	switch out.Kind() {
+	case reflect.Interface:
+		out.Set(reflect.ValueOf(in))
+	case reflect.String:
+		s, ok := in.(string)
+		if !ok {
+			return fieldError(field, "expected a string, got %s", typeName(in))
+		}
+		out.SetString(s)
+	case reflect.Bool:
+		switch b := in.(type) {
+		case bool:
+			out.SetBool(b)
+		case string:
+			v, err := strconv.ParseBool(b)
+			if err != nil {
+				return fieldError(field, "expected a boolean, got %q", b)
+			}
+			out.SetBool(v)
+		default:
+			return fieldError(field, "expected a boolean, got %s", typeName(in))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := toInt(in)
+		if err != nil {
+			return fieldError(field, "%v", err)
+		}
+		if out.OverflowInt(n) {
+			return fieldError(field, "%d overflows %s", n, out.Type())
+		}
+		out.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := toInt(in)
+		if err != nil {
+			return fieldError(field, "%v", err)
+		}
+		if n < 0 || out.OverflowUint(uint64(n)) {
+			return fieldError(field, "%d overflows %s", n, out.Type())
+		}
+		out.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat(in)
+		if err != nil {
+			return fieldError(field, "%v", err)
+		}
+		out.SetFloat(f)
+	case reflect.Slice:
+		list := reflect.ValueOf(in)
+		if list.Kind() != reflect.Slice {
+			return fieldError(field, "expected a list, got %s", typeName(in))
+		}
+		s := reflect.MakeSlice(out.Type(), list.Len(), list.Len())
+		for i := 0; i < list.Len(); i++ {
+			if err := decodeValue(field+"["+strconv.Itoa(i)+"]", list.Index(i).Interface(), s.Index(i)); err != nil {
+				return err
+			}
+		}
+		out.Set(s)
+	case reflect.Map:
+		m, ok := toStringMap(in)
+		if !ok || out.Type().Key().Kind() != reflect.String {
+			return fieldError(field, "expected a table, got %s", typeName(in))
+		}
+		mv := reflect.MakeMapWithSize(out.Type(), len(m))
+		for k, x := range m {
+			v := reflect.New(out.Type().Elem()).Elem()
+			if err := decodeValue(join(field, k), x, v); err != nil {
+				return err
+			}
+			mv.SetMapIndex(reflect.ValueOf(k).Convert(out.Type().Key()), v)
+		}
+		out.Set(mv)
+	case reflect.Struct:
+		m, ok := toStringMap(in)
+		if !ok {
+			return fieldError(field, "expected a table, got %s", typeName(in))
+		}
+		return decodeStruct(field, m, out)
+	default:
+		return fieldError(field, "unsupported type %s", out.Type())
+	}
+	return nil
+}
+
+// decodeStruct 按 json tag 解析结构体字段, 不允许未知字段
+func decodeStruct(field string, m map[string]interface{}, out reflect.Value) error {
+	fields := make(map[string]reflect.Value, out.NumField())
+	t := out.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = out.Field(i)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // 错误总是指向第一个有问题的字段
+	for _, k := range keys {
+		v, ok := fields[k]
+		if !ok {
+			return fieldError(join(field, k), "unknown field")
+		}
+		if err := decodeValue(join(field, k), m[k], v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeDuration 解析 "4m" 形式的时长, 整数视为秒数
+func decodeDuration(field string, in interface{}, out reflect.Value) error {
+	if s, ok := in.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			out.SetInt(int64(d))
+			return nil
+		}
+	}
+	n, err := toInt(in)
+	if err != nil {
+		if s, ok := in.(string); ok {
+			return fieldError(field, "invalid duration %q", s)
+		}
+		return fieldError(field, "expected a duration such as \"4m\", \"1.5s\" or an integer number of seconds, got %s", typeName(in))
+	}
+	if n > math.MaxInt64/int64(time.Second) || n < math.MinInt64/int64(time.Second) {
+		return fieldError(field, "%d seconds overflows time.Duration", n)
+	}
+	out.SetInt(n * int64(time.Second))
+	return nil
+}
+
+func toInt(in interface{}) (int64, error) {
+	switch n := in.(type) {
+	case int:
+		return int64(n), nil
+	case int64:
+		return n, nil
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, fmt.Errorf("%d overflows int64", n)
+		}
+		return int64(n), nil
+	case float64:
+		if n != math.Trunc(n) || n > math.MaxInt64 || n < math.MinInt64 {
+			return 0, fmt.Errorf("expected an integer, got %v", n)
+		}
+		return int64(n), nil
+	case json.Number:
+		return toInt(string(n))
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected an integer, got %q", n)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("expected an integer, got %s", typeName(in))
+}
+
+func toFloat(in interface{}) (float64, error) {
+	switch n := in.(type) {
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected a number, got %q", n)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("expected a number, got %s", typeName(in))
+}
+
+// toStringMap 将各格式解析出的表转换为 map[string]interface{}
+func toStringMap(in interface{}) (map[string]interface{}, bool) {
+	switch m := in.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		r := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			r[fmt.Sprint(k)] = v
+		}
+		return r, true
+	}
+	return nil, false
+}
+
+func typeName(in interface{}) string {
+	switch in.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case int, int64, uint64, float64, json.Number:
+		return "a number"
+	case nil:
+		return "null"
+	}
+	if _, ok := toStringMap(in); ok {
+		return "a table"
+	}
+	if reflect.ValueOf(in).Kind() == reflect.Slice {
+		return "a list"
+	}
+	return fmt.Sprintf("%T", in)
+}
+
+func join(field, key string) string {
+	if field == "" {
+		return key
+	}
+	return field + "." + key
+}
+
+// expandTree 替换 in 中所有字符串的环境变量, 返回新的副本
+func expandTree(field string, in interface{}) (interface{}, error) {
+	switch x := in.(type) {
+	case string:
+		s, err := expandEnv(x)
+		if err != nil {
+			return nil, fieldError(field, "%v", err)
+		}
+		return s, nil
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, v := range x {
+			e, err := expandTree(field+"["+strconv.Itoa(i)+"]", v)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = e
+		}
+		return out, nil
+	}
+	m, ok := toStringMap(in)
+	if !ok {
+		return in, nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // 错误总是指向第一个有问题的字段
+	out := make(map[string]interface{}, len(m))
+	for _, k := range keys {
+		e, err := expandTree(join(field, k), m[k])
+		if err != nil {
+			return nil, err
+		}
+		out[k] = e
+	}
+	return out, nil
+}
+
+// expandEnv 替换 s 中的 ${VAR} 与 ${VAR:-default}, $$ 表示 $
+func expandEnv(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			sb.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			expr := s[i+2 : i+2+end]
+			name, def, hasDef := strings.Cut(expr, ":-")
+			v, ok := os.LookupEnv(name)
+			switch {
+			case ok && (v != "" || !hasDef):
+				sb.WriteString(v)
+			case hasDef:
+				sb.WriteString(def)
+			default:
+				return "", fmt.Errorf("environment variable %s is not set", name)
+			}
+			i += 2 + end
+		default:
+			sb.WriteByte('$')
+		}
+	}
+	return sb.String(), nil
+}
@@ -1,9 +1,14 @@
 package zero
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"reflect"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 	"unsafe"
 
 	"github.com/wdvxdr1123/ZeroBot/message"
@@ -16,10 +21,19 @@ type Ctx struct {
 	Event  *Event
 	State  State
 	caller APICaller
+	v12    bool // 是否使用 OneBot 12 协议
+	ctx    context.Context
+	cancel context.CancelFunc
+	// finished 事件处理已正常结束, ctx 已取消以释放资源
+	finished uint32
 
 	// lazy message
 	once    sync.Once
 	message string
+
+	// 筛选候选 Matcher 时的索引与消息, 消息被改写后退回遍历完整列表
+	index *matcherIndex
+	key   messageKey
 }
 
 // GetMatcher ...
@@ -37,6 +51,34 @@ func (ctx *Ctx) GetState() State {
 	return ctx.State
 }
 
+// GetContext 获取本次事件处理的 context.Context
+//
+//	事件处理达到最大时延时被取消, 通过 ctx 发起的 API 调用也会一并取消;
+//	处理正常结束后返回仅在 Shutdown 时取消的 context, 供 Handler 启动的 goroutine 继续使用
+func (ctx *Ctx) GetContext() context.Context {
+	if ctx.ctx == nil {
+		return context.Background()
+	}
+	if atomic.LoadUint32(&ctx.finished) != 0 {
+		return context.WithValue(basectx, eventContextKey{}, ctx.Event)
+	}
+	return ctx.ctx
+}
+
+// finish 在事件处理结束后释放 ctx.ctx, 避免其留在 basectx 中
+func (ctx *Ctx) finish() {
+	if ctx.cancel != nil {
+		atomic.StoreUint32(&ctx.finished, 1)
+		ctx.cancel()
+	}
+}
+
+func (ctx *Ctx) cancelCalls() {
+	if ctx.cancel != nil {
+		ctx.cancel()
+	}
+}
+
 func (ctx *Ctx) getMatcher() IMatcher {
 	return ctx.ma
 }
@@ -62,6 +104,8 @@ type dec struct {
 var decoderCache = sync.Map{}
 
 // Parse 将 Ctx.State 映射到结构体
+//
+//	数值类型不同时按字段类型转换
 func (ctx *Ctx) Parse(model interface{}) (err error) {
 	var (
 		rv       = reflect.ValueOf(model).Elem()
@@ -90,11 +134,54 @@ func (ctx *Ctx) Parse(model interface{}) (err error) {
 		decoderCache.Store(t, modelDec)
 	}
 	for _, d := range modelDec { // decoder类型非小内存，无法被编译器优化为快速拷贝
-		rv.Field(d.index).Set(reflect.ValueOf(ctx.State[d.key]))
+		f, v := rv.Field(d.index), reflect.ValueOf(ctx.State[d.key])
+		if v.IsValid() && !v.Type().AssignableTo(f.Type()) && isNumber(v.Kind()) && isNumber(f.Kind()) {
+			if !convertible(v, f) {
+				return fmt.Errorf("parse state error: %s: cannot convert %v to %s without loss", d.key, v, f.Type())
+			}
+			v = v.Convert(f.Type()) // 如命令参数的 int64 到 int
+		}
+		f.Set(v)
 	}
 	return nil
 }
 
+func isNumber(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
+}
+
+// convertible 数值 v 能否无溢出与精度损失地转换为 f 的类型
+func convertible(v, f reflect.Value) bool {
+	switch {
+	case f.CanInt():
+		switch {
+		case v.CanInt():
+			return !f.OverflowInt(v.Int())
+		case v.CanUint():
+			return v.Uint() <= math.MaxInt64 && !f.OverflowInt(int64(v.Uint()))
+		}
+		x := v.Float()
+		return x == math.Trunc(x) && x >= math.MinInt64 && x < math.MaxInt64 && !f.OverflowInt(int64(x))
+	case f.CanUint():
+		switch {
+		case v.CanInt():
+			return v.Int() >= 0 && !f.OverflowUint(uint64(v.Int()))
+		case v.CanUint():
+			return !f.OverflowUint(v.Uint())
+		}
+		x := v.Float()
+		return x == math.Trunc(x) && x >= 0 && x < math.MaxUint64 && !f.OverflowUint(uint64(x))
+	}
+	c := v.Convert(f.Type())
+	switch {
+	case v.CanInt():
+		return c.Float() == math.Trunc(c.Float()) && c.Float() >= math.MinInt64 && c.Float() < math.MaxInt64 && int64(c.Float()) == v.Int()
+	case v.CanUint():
+		return c.Float() < math.MaxUint64 && uint64(c.Float()) == v.Uint()
+	}
+	return !f.OverflowFloat(v.Float())
+}
+
 // CheckSession 判断会话连续性
 func (ctx *Ctx) CheckSession() Rule {
 	return func(ctx2 Context) bool {
@@ -104,6 +191,8 @@ func (ctx *Ctx) CheckSession() Rule {
 }
 
 // Send 快捷发送消息/合并转发
+//
+//	超过 BotConfig.MaxMessageLen 或 MaxMessageSegs 的消息按 BotConfig.LengthPolicy 处理, 返回第一条消息的 ID
 func (ctx *Ctx) Send(msg interface{}) message.MessageID {
 	event := ctx.Event
 	m, ok := msg.(message.Message)
@@ -114,6 +203,14 @@ func (ctx *Ctx) Send(msg interface{}) message.MessageID {
 			m = *p
 		}
 	}
+	if !ok {
+		if s, isstr := msg.(string); isstr && GetConfig().MaxMessageLen > 0 && utf8.RuneCountInString(s) > GetConfig().MaxMessageLen {
+			m, ok = message.ParseMessageFromString(s), true
+		}
+	}
+	if ok && len(m) > 0 && m[0].Type != "node" && isOverLength(m) {
+		return ctx.sendOverLength(m)
+	}
 	if ok && len(m) > 0 && m[0].Type == "node" && event.DetailType != "guild" {
 		if event.GroupID != 0 {
 			return message.NewMessageIDFromInteger(ctx.SendGroupForwardMessage(event.GroupID, m).Get("message_id").Int())
@@ -129,6 +226,38 @@ func (ctx *Ctx) Send(msg interface{}) message.MessageID {
 	return message.NewMessageIDFromInteger(ctx.SendPrivateMessage(event.UserID, msg))
 }
 
+func isOverLength(m message.Message) bool {
+	cfg := GetConfig()
+	return (cfg.MaxMessageSegs > 0 && len(m) > cfg.MaxMessageSegs) ||
+		(cfg.MaxMessageLen > 0 && m.TextLen() > cfg.MaxMessageLen)
+}
+
+// sendOverLength 按 BotConfig.LengthPolicy 发送超长消息, 返回第一条消息的 ID
+func (ctx *Ctx) sendOverLength(m message.Message) message.MessageID {
+	event := ctx.Event
+	cfg := GetConfig()
+	chunks := message.Split(m, cfg.MaxMessageLen, cfg.MaxMessageSegs)
+	if cfg.LengthPolicy == ForwardOverLength && event.DetailType != "guild" {
+		name := strconv.FormatInt(event.SelfID, 10)
+		if len(cfg.NickName) > 0 {
+			name = cfg.NickName[0]
+		}
+		nodes := make(message.Message, len(chunks))
+		for i, c := range chunks {
+			nodes[i] = message.CustomNode(name, event.SelfID, c)
+		}
+		return ctx.Send(nodes)
+	}
+	var first message.MessageID
+	for i, c := range chunks {
+		id := ctx.Send(c)
+		if i == 0 {
+			first = id
+		}
+	}
+	return first
+}
+
 // SendChain 快捷发送消息/合并转发-消息链
 func (ctx *Ctx) SendChain(msg ...message.MessageSegment) message.MessageID {
 	return ctx.Send((message.Message)(msg))
@@ -136,10 +265,11 @@ func (ctx *Ctx) SendChain(msg ...message.MessageSegment) message.MessageID {
 
 // Echo 向自身分发虚拟事件
 func (ctx *Ctx) Echo(response []byte) {
-	if BotConfig.RingLen != 0 {
+	cfg := GetConfig()
+	if cfg.RingLen != 0 {
 		evring.processEvent(response, ctx.caller)
 	} else {
-		processEventAsync(response, ctx.caller, BotConfig.MaxProcessTime)
+		processEventAsync(response, ctx.caller, cfg.MaxProcessTime)
 	}
 }
 
@@ -148,12 +278,16 @@ func (ctx *Ctx) FutureEvent(Type string, rule ...Rule) *FutureEvent {
 	return ctx.ma.FutureEvent(Type, rule...)
 }
 
-// Get ..
+// Get 发送 prompt 并等待同一会话的下一条消息, Shutdown 时返回空字符串
 func (ctx *Ctx) Get(prompt string) string {
 	if prompt != "" {
 		ctx.Send(prompt)
 	}
-	return (<-ctx.FutureEvent("message", ctx.CheckSession()).Next()).GetEvent().RawMessage
+	next, ok := <-ctx.FutureEvent("message", ctx.CheckSession()).Next()
+	if !ok { // Shutdown
+		return ""
+	}
+	return next.GetEvent().RawMessage
 }
 
 // ExtractPlainText 提取消息中的纯文本
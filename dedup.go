@@ -0,0 +1,135 @@
+package zero
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DedupConfig 多个账号在同一群时, 同一事件只由一个账号处理
+type DedupConfig struct {
+	// Window 同一事件由不同账号上报的最大时间差 (默认10s)
+	Window time.Duration `json:"window"`
+	// Owner 选择群内处理事件的账号, 为 nil 时由最先上报的账号处理
+	Owner DedupPolicy `json:"-"`
+}
+
+// DedupPolicy 选择群 gid 内处理事件的账号
+//
+//	bots 为已知在该群内且在线的账号, 按 ID 升序, 至少两个
+type DedupPolicy func(gid int64, bots []int64) int64
+
+// DedupPrimary 由 primary 中靠前的账号处理, 均不在群内时由 ID 最小的账号处理
+func DedupPrimary(primary ...int64) DedupPolicy {
+	order := RoutePrimary(primary...)
+	return func(gid int64, bots []int64) int64 {
+		return order(gid, bots)[0]
+	}
+}
+
+var dedup = deduper{m: make(map[string]dedupEntry, 256)}
+
+// deduper 记录窗口内各群事件的首个上报账号
+type deduper struct {
+	mu    sync.Mutex
+	m     map[string]dedupEntry
+	sweep time.Time
+}
+
+type dedupEntry struct {
+	selfID int64
+	seen   time.Time
+}
+
+// dedupKey 群号, 发送者与内容相同的事件视为同一事件
+//
+//	各账号收到的 message_id 不同, 故不使用 message_id
+func dedupKey(e *Event) string {
+	var sb strings.Builder
+	sb.WriteString(strconv.FormatInt(e.GroupID, 10))
+	sb.WriteByte('|')
+	sb.WriteString(strconv.FormatInt(e.UserID, 10))
+	sb.WriteByte('|')
+	sb.WriteString(e.PostType)
+	sb.WriteByte('|')
+	sb.WriteString(e.DetailType)
+	sb.WriteByte('|')
+	if e.PostType == "message" {
+		sb.WriteString(e.RawMessage)
+		return sb.String()
+	}
+	sb.WriteString(e.SubType)
+	sb.WriteByte('|')
+	sb.WriteString(strconv.FormatInt(e.OperatorID, 10))
+	sb.WriteByte('|')
+	sb.WriteString(strconv.FormatInt(e.TargetID, 10))
+	sb.WriteByte('|')
+	sb.WriteString(e.Comment)
+	return sb.String()
+}
+
+// duplicate 判断 e 是否应由其它账号处理
+func (d *deduper) duplicate(e *Event, c *DedupConfig) bool {
+	if e.GroupID == 0 || e.SelfID == 0 {
+		return false
+	}
+	switch e.PostType {
+	case "message", "notice", "request":
+	default:
+		return false
+	}
+	window := c.Window
+	if window <= 0 {
+		window = 10 * time.Second
+	}
+	if c.Owner != nil {
+		if owner := dedupOwner(e.GroupID, c.Owner); owner != 0 {
+			return owner != e.SelfID
+		}
+	}
+	key := dedupKey(e)
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if now.Sub(d.sweep) > window {
+		for k, v := range d.m {
+			if now.Sub(v.seen) > window {
+				delete(d.m, k)
+			}
+		}
+		d.sweep = now
+	}
+	if v, ok := d.m[key]; ok && v.selfID != e.SelfID && now.Sub(v.seen) <= window {
+		return true
+	}
+	d.m[key] = dedupEntry{selfID: e.SelfID, seen: now} // 同一账号再次上报视为新事件
+	return false
+}
+
+// dedupOwner 群内有多个在线账号时返回处理者, 否则返回 0
+func dedupOwner(gid int64, policy DedupPolicy) int64 {
+	bots := GroupBots(gid)
+	online := bots[:0]
+	for _, id := range bots {
+		if _, ok := APICallers.Load(id); ok {
+			online = append(online, id)
+		}
+	}
+	if len(online) < 2 {
+		return 0
+	}
+	return policy(gid, online)
+}
+
+// isDuplicate 启用去重时判断 e 是否应由其它账号处理
+func isDuplicate(e *Event) bool {
+	c := GetConfig().Dedup
+	if c == nil || !dedup.duplicate(e, c) {
+		return false
+	}
+	log.Debugf("[dedup] 忽略账号 %d 在群 %d 收到的重复事件", e.SelfID, e.GroupID)
+	return true
+}
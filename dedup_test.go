@@ -0,0 +1,36 @@
+package zero
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedup(t *testing.T) {
+	d := deduper{m: make(map[string]dedupEntry)}
+	c := &DedupConfig{}
+	event := func(selfID int64, text string) *Event {
+		return &Event{SelfID: selfID, GroupID: 5, UserID: 9, PostType: "message", DetailType: "group", RawMessage: text}
+	}
+	assert.False(t, d.duplicate(event(40001, "hi"), c))
+	assert.True(t, d.duplicate(event(40002, "hi"), c))
+	assert.False(t, d.duplicate(event(40001, "hi"), c)) // 用户再次发送
+	assert.False(t, d.duplicate(event(40002, "hello"), c))
+	assert.True(t, d.duplicate(event(40001, "hello"), c))
+	assert.False(t, d.duplicate(&Event{SelfID: 40002, UserID: 9, PostType: "message", DetailType: "private", RawMessage: "hi"}, c))
+
+	APICallers.Store(40001, mockCaller{})
+	APICallers.Store(40002, mockCaller{})
+	defer APICallers.Delete(40001)
+	defer APICallers.Delete(40002)
+	routes.mu.Lock()
+	routes.join(5, 40001)
+	routes.join(5, 40002)
+	routes.mu.Unlock()
+	c.Owner = DedupPrimary(40002)
+	assert.True(t, d.duplicate(event(40001, "owner"), c))
+	assert.False(t, d.duplicate(event(40002, "owner"), c))
+	c.Owner = DedupPrimary()
+	assert.False(t, d.duplicate(event(40001, "owner"), c))
+	assert.True(t, d.duplicate(event(40002, "owner"), c))
+}
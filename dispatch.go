@@ -0,0 +1,278 @@
+package zero
+
+import (
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
+	"github.com/wdvxdr1123/ZeroBot/utils/helper"
+)
+
+// Matcher 的触发方式, 见 MatcherInfo.Kind
+const (
+	TriggerOn        = "on"        // On OnMessage OnNotice 等
+	TriggerCommand   = "command"   // OnCommand OnCommandGroup
+	TriggerPrefix    = "prefix"    // OnPrefix OnPrefixGroup
+	TriggerSuffix    = "suffix"    // OnSuffix OnSuffixGroup
+	TriggerRegex     = "regex"     // OnRegex
+	TriggerKeyword   = "keyword"   // OnKeyword OnKeywordGroup
+	TriggerFullMatch = "fullmatch" // OnFullMatch OnFullMatchGroup
+	TriggerShell     = "shell"     // OnShell
+	TriggerFuture    = "future"    // FutureEvent
+)
+
+// trigger 注册 Matcher 时记录的触发方式, 用于索引与 ListMatchers
+//
+//	typ 为 On 的 typ 参数, 未知时为空, 匹配所有事件;
+//	command prefix fullmatch shell 的 literals 为消息须满足其一的字面量, 按字面量索引
+type trigger struct {
+	kind     string
+	typ      string
+	literals []string // 命令不含 CommandPrefix
+	regex    string
+	replaced bool // 已通过 SetRules 替换规则, 不再按字面量索引
+	file     string
+	line     int
+}
+
+func (t *trigger) literal() bool {
+	if t.replaced || len(t.literals) == 0 {
+		return false
+	}
+	switch t.kind {
+	case TriggerCommand, TriggerShell, TriggerPrefix, TriggerFullMatch:
+		return true
+	}
+	return false
+}
+
+// trieNode 字节前缀树
+type trieNode struct {
+	next     map[byte]*trieNode
+	matchers []int // 以该节点结尾的字面量对应的 Matcher 序号
+}
+
+func (n *trieNode) insert(s string, i int) {
+	for j := 0; j < len(s); j++ {
+		if n.next == nil {
+			n.next = make(map[byte]*trieNode, 2)
+		}
+		c, ok := n.next[s[j]]
+		if !ok {
+			c = new(trieNode)
+			n.next[s[j]] = c
+		}
+		n = c
+	}
+	n.matchers = append(n.matchers, i)
+}
+
+// walk 对 s 的每个已注册前缀调用 f
+func (n *trieNode) walk(s string, f func(i int)) {
+	for j := 0; ; j++ {
+		for _, i := range n.matchers {
+			f(i)
+		}
+		if j == len(s) || n.next == nil {
+			return
+		}
+		if n = n.next[s[j]]; n == nil {
+			return
+		}
+	}
+}
+
+// matcherIndex 按事件类型与消息字面量索引的 Matcher 快照
+//
+//	序号为 Matcher 在按优先级排序的列表中的位置, 候选 Matcher 按序号排序以保持优先级与 Block 语义
+type matcherIndex struct {
+	matchers []IMatcher
+	types    map[string][]int // "" "post_type" "post_type/detail_type" -> 序号
+	commands trieNode
+	prefixes trieNode
+	fulls    map[string][]int
+}
+
+func newMatcherIndex(matchers []IMatcher) *matcherIndex {
+	idx := &matcherIndex{
+		matchers: matchers,
+		types:    make(map[string][]int, 16),
+		fulls:    make(map[string][]int),
+	}
+	for i, im := range matchers {
+		m, ok := im.(*Matcher)
+		if !ok || !m.trigger.literal() {
+			var t string
+			if ok {
+				t = typeKey(m.trigger.typ)
+			}
+			idx.types[t] = append(idx.types[t], i)
+			continue
+		}
+		for _, l := range m.trigger.literals {
+			switch m.trigger.kind {
+			case TriggerCommand, TriggerShell:
+				idx.commands.insert(l, i)
+			case TriggerPrefix:
+				idx.prefixes.insert(l, i)
+			case TriggerFullMatch:
+				idx.fulls[l] = append(idx.fulls[l], i)
+			}
+		}
+	}
+	return idx
+}
+
+// typeKey 取 post_type/detail_type 部分, sub_type 由 Type 规则判断
+func typeKey(typ string) string {
+	t := strings.SplitN(typ, "/", 3)
+	if len(t) > 2 {
+		return t[0] + "/" + t[1]
+	}
+	return typ
+}
+
+// messageKey 候选 Matcher 所依据的消息内容
+type messageKey struct {
+	text   string // 首段文本
+	isText bool   // 首段为文本
+	single bool   // 仅有一段
+}
+
+func keyOf(e *Event) (k messageKey) {
+	if e.PostType != "message" || len(e.Message) == 0 {
+		return
+	}
+	k.single = len(e.Message) == 1
+	if e.Message[0].Type == "text" {
+		k.text, k.isText = e.Message[0].Data["text"], true
+	}
+	return
+}
+
+// candidates 返回可能匹配 ctx 事件的 Matcher, 按优先级排序
+//
+//	不调用 ctx.MessageString, 以免缓存之后可能被改写的消息
+func (idx *matcherIndex) candidates(ctx *Ctx) []IMatcher {
+	e := ctx.Event
+	seen := make(map[int]struct{}, 8)
+	add := func(i int) { seen[i] = struct{}{} }
+	for _, i := range idx.types[""] {
+		add(i)
+	}
+	for _, i := range idx.types[e.PostType] {
+		add(i)
+	}
+	for _, i := range idx.types[e.PostType+"/"+e.DetailType] {
+		add(i)
+	}
+	k := keyOf(e)
+	if k.isText {
+		if prefix := GetConfig().CommandPrefix; strings.HasPrefix(k.text, prefix) {
+			idx.commands.walk(k.text[len(prefix):], add)
+		}
+		idx.prefixes.walk(k.text, add)
+	}
+	switch {
+	case e.PostType != "message" || len(idx.fulls) == 0:
+	case k.single && k.isText: // 此时 MessageString 即为转义后的文本
+		for _, i := range idx.fulls[message.EscapeCQText(k.text)] {
+			add(i)
+		}
+	default: // 含其它消息段, 由 FullMatchRule 判断
+		for _, full := range idx.fulls {
+			for _, i := range full {
+				add(i)
+			}
+		}
+	}
+	ctx.index, ctx.key = idx, k
+	order := make([]int, 0, len(seen))
+	for i := range seen {
+		order = append(order, i)
+	}
+	sort.Ints(order)
+	matchers := make([]IMatcher, len(order))
+	for j, i := range order {
+		matchers[j] = idx.matchers[i]
+	}
+	return matchers
+}
+
+// fallback 消息在 matchers[i] 处理时被改写, 则其后的候选替换为完整列表中位于其后的所有 Matcher
+func (ctx *Ctx) fallback(matchers []IMatcher, i int) []IMatcher {
+	if ctx.index == nil || keyOf(ctx.Event) == ctx.key {
+		return matchers
+	}
+	all := ctx.index.matchers
+	ctx.index = nil
+	for j, m := range all {
+		if m == matchers[i] {
+			return append(matchers[:i+1:i+1], all[j+1:]...)
+		}
+	}
+	return matchers
+}
+
+// dispatchIndex 当前的索引, 在 matcherList 改变后重建
+var dispatchIndex = newMatcherIndex(nil)
+
+// currentIndex 返回最新的 Matcher 索引
+func currentIndex() *matcherIndex {
+	matcherLock.Lock()
+	defer matcherLock.Unlock()
+	if hasMatcherListChanged {
+		matchers := make([]IMatcher, len(matcherList))
+		copy(matchers, matcherList)
+		dispatchIndex = newMatcherIndex(matchers)
+		hasMatcherListChanged = false
+	}
+	return dispatchIndex
+}
+
+// syncRules 无阻塞且不调用 API 的规则的代码指针, 这些规则在当前 goroutine 中执行
+var syncRules = map[uintptr]struct{}{}
+
+func funcPC(r Rule) uintptr {
+	return reflect.ValueOf(r).Pointer()
+}
+
+func init() {
+	for _, r := range []Rule{
+		Type(""), PrefixRule(), SuffixRule(), CommandRule(), RegexRule(""), ReplyRule(0),
+		KeywordRule(), FullMatchRule(), CheckUser(), CheckGroup(), SyncRule(nil), (&CommandNode{}).rule(),
+		OnlyToMe, OnlyPrivate, OnlyPublic, OnlyGroup, OnlyGuild,
+		SuperUserPermission, AdminPermission, OwnerPermission, UserOrGrpAdmin, HasPicture,
+	} {
+		syncRules[funcPC(r)] = struct{}{}
+	}
+}
+
+// SyncRule 标记 rule 无阻塞且不调用 API, 匹配时将不为其创建 goroutine
+//
+//	此类规则不受 MaxProcessTime 限制
+func SyncRule(rule Rule) Rule {
+	return func(ctx Context) bool {
+		return rule(ctx)
+	}
+}
+
+func isSyncRule(rule Rule) bool {
+	_, ok := syncRules[funcPC(rule)]
+	return ok
+}
+
+// runSyncRule 在当前 goroutine 中执行 rule, panic 时返回 false
+func runSyncRule(ctx Context, rule Rule) (pass bool) {
+	defer func() {
+		if pa := recover(); pa != nil {
+			log.Errorf("[bot] execute rule err: %v\n%v", pa, helper.BytesToString(debug.Stack()))
+			pass = false
+		}
+	}()
+	return rule(ctx)
+}
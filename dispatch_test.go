@@ -0,0 +1,180 @@
+package zero
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/wdvxdr1123/ZeroBot/extension/rate"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+func messageCtx(text string) *Ctx {
+	return &Ctx{
+		Event: &Event{PostType: "message", DetailType: "group", GroupID: 1, UserID: 2,
+			Message: message.Message{message.Text(text)}},
+		State: State{},
+	}
+}
+
+func TestMatcherIndex(t *testing.T) {
+	e := New()
+	defer e.Delete()
+	cmd := e.OnCommand("签到").SetPriority(5)
+	grp := e.OnCommandGroup([]string{"sign", "signin"})
+	pre := e.OnPrefix("查询").SetPriority(1)
+	full := e.OnFullMatch("你好")
+	notice := e.On("notice/group_increase")
+	msg := e.OnMessage().SetPriority(10)
+	any := e.On("")
+
+	idx := currentIndex()
+	oldPrefix := BotConfig.CommandPrefix
+	BotConfig.CommandPrefix = "/"
+	defer func() { BotConfig.CommandPrefix = oldPrefix }()
+
+	assert.Equal(t, []IMatcher{any, cmd, msg}, idx.candidates(messageCtx("/签到 1")))
+	assert.Equal(t, []IMatcher{grp, any, msg}, idx.candidates(messageCtx("/signin"))) // sign 与 signin 只出现一次
+	assert.Equal(t, []IMatcher{any, pre, msg}, idx.candidates(messageCtx("查询天气")))
+	ctx := messageCtx("你好")
+	assert.Equal(t, []IMatcher{full, any, msg}, idx.candidates(ctx))
+	assert.Empty(t, ctx.message) // 未缓存 MessageString
+	ctx.Event.Message = append(ctx.Event.Message, message.Face(1))
+	assert.Equal(t, []IMatcher{full, any, msg}, idx.candidates(ctx)) // 含其它消息段时由规则判断
+	assert.Equal(t, []IMatcher{any, msg}, idx.candidates(messageCtx("签到")))
+	assert.Equal(t, []IMatcher{notice, any},
+		idx.candidates(&Ctx{Event: &Event{PostType: "notice", DetailType: "group_increase"}}))
+
+	cmd.SetRules() // 替换规则后不再按字面量索引
+	assert.Equal(t, []IMatcher{any, cmd, msg}, currentIndex().candidates(messageCtx("签到")))
+}
+
+func TestMatcherIndexRewrite(t *testing.T) {
+	e := New()
+	defer e.Delete()
+	oldPrefix := BotConfig.CommandPrefix
+	BotConfig.CommandPrefix = "/"
+	defer func() { BotConfig.CommandPrefix = oldPrefix }()
+	e.OnMessage().Handle(func(ctx Context) { // 预处理, 将消息改写为命令
+		ctx.GetEvent().Message = message.Message{message.Text("/签到")}
+	})
+	var got bool
+	e.OnCommand("签到").SetPriority(1).Handle(func(Context) { got = true })
+
+	ctx := messageCtx("qd")
+	tm := time.NewTimer(time.Minute)
+	defer tm.Stop()
+	processMatchers(ctx, currentIndex().candidates(ctx), tm)
+	assert.True(t, got)
+}
+
+func TestMatcherLimit(t *testing.T) {
+	e := New()
+	defer e.Delete()
+	var n int
+	m := e.OnCommand("签到").Handle(func(Context) { n++ })
+	idx := currentIndex()
+	done := make(chan struct{})
+	go func() { // 与处理事件并发追加规则
+		defer close(done)
+		tm := time.NewTimer(time.Minute)
+		defer tm.Stop()
+		processMatchers(messageCtx("签到"), []IMatcher{m}, tm)
+	}()
+	limiter := rate.NewLimiter(time.Hour, 1)
+	m.Limit(func(Context) *rate.Limiter { return limiter })
+	<-done
+	assert.NotSame(t, idx, currentIndex()) // 追加规则后重建索引
+
+	n = 0
+	for i := 0; i < 2; i++ {
+		ctx := messageCtx("签到")
+		tm := time.NewTimer(time.Minute)
+		processMatchers(ctx, currentIndex().candidates(ctx), tm)
+		tm.Stop()
+	}
+	assert.LessOrEqual(t, n, 1)
+}
+
+func TestSyncRule(t *testing.T) {
+	assert.True(t, isSyncRule(CommandRule("a")))
+	assert.True(t, isSyncRule(OnlyGroup))
+	assert.True(t, isSyncRule(SyncRule(func(Context) bool { return true })))
+	assert.False(t, isSyncRule(func(Context) bool { return true }))
+	assert.False(t, isSyncRule(MustProvidePicture))
+
+	ctx := messageCtx("")
+	ctx.Event.Sender = nil
+	assert.False(t, runSyncRule(ctx, AdminPermission)) // panic 视为不通过
+}
+
+// benchmarkDispatch 注册 n 个命令后, 分发一条命中最后一个命令的群消息
+//
+//	legacy 时遍历所有 Matcher 且每个规则都创建 goroutine, 与索引前的行为相同
+func benchmarkDispatch(b *testing.B, n int, legacy bool) {
+	e := New().(*Engine)
+	defer e.Delete()
+	async := func(r Rule) Rule { return func(ctx Context) bool { return r(ctx) } }
+	for i := 0; i < n; i++ {
+		cmd := fmt.Sprintf("cmd%03d", i)
+		if legacy {
+			m := &Matcher{Type: Type("message"), Rules: []Rule{async(CommandRule(cmd)), async(OnlyGroup)}, Block: true, Engine: e}
+			e.matchers = append(e.matchers, m)
+			StoreMatcher(m).Handle(func(Context) {})
+			continue
+		}
+		e.OnCommand(cmd, OnlyGroup).SetBlock(true).Handle(func(Context) {})
+	}
+	oldPrefix := BotConfig.CommandPrefix
+	BotConfig.CommandPrefix = "/"
+	defer func() { BotConfig.CommandPrefix = oldPrefix }()
+	idx := currentIndex()
+	text := fmt.Sprintf("/cmd%03d", n-1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := messageCtx(text)
+		matchers := idx.matchers
+		if !legacy {
+			matchers = idx.candidates(ctx)
+		}
+		t := time.NewTimer(time.Minute)
+		processMatchers(ctx, matchers, t)
+		t.Stop()
+	}
+}
+
+func BenchmarkDispatchLegacy300(b *testing.B)  { benchmarkDispatch(b, 300, true) }
+func BenchmarkDispatchIndexed300(b *testing.B) { benchmarkDispatch(b, 300, false) }
+
+func TestProcessMatchersBreak(t *testing.T) {
+	deny := func(Context) bool { return false }
+	for name, setup := range map[string]func(e *Engine, m IMatcher){
+		"preHandler": func(e *Engine, _ IMatcher) { e.UsePreHandler(deny) },
+		"rule":       func(_ *Engine, m IMatcher) { m.SetRules(deny) },
+		"midHandler": func(e *Engine, _ IMatcher) { e.UseMidHandler(deny) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			e := New().(*Engine)
+			defer e.Delete()
+			var first, second bool
+			m1 := e.OnMessage().Handle(func(Context) { first = true })
+			m2 := OnMessage().SetPriority(1).Handle(func(Context) { second = true })
+			defer m2.Delete()
+			setup(e, m1)
+
+			tm := time.NewTimer(time.Minute)
+			defer tm.Stop()
+			processMatchers(messageCtx("hi"), []IMatcher{m1, m2}, tm)
+			assert.False(t, first)
+			assert.True(t, second) // 未通过时继续匹配下一个
+
+			m1.SetBreak(true)
+			second = false
+			processMatchers(messageCtx("hi"), []IMatcher{m1, m2}, tm)
+			assert.False(t, second) // Break 时结束匹配
+		})
+	}
+}
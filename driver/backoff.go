@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff 断线重连的指数退避策略, 零值字段使用 DefaultBackoff 中的值
+type Backoff struct {
+	Initial     time.Duration // 首次重试前的等待时间
+	Max         time.Duration // 最长等待时间
+	Multiplier  float64       // 每次失败后等待时间的倍数
+	Jitter      float64       // 随机抖动比例, 实际等待时间在 d*(1-Jitter) 与 d*(1+Jitter) 之间, 为负时不抖动
+	MaxAttempts int           // 最大连续重试次数, 为 0 时不限
+}
+
+// DefaultBackoff 默认退避策略: 1s 2s 4s ... 最长 1min, 抖动 20%, 不限次数
+var DefaultBackoff = Backoff{
+	Initial:    time.Second,
+	Max:        time.Minute,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Duration 第 n 次 (从 0 开始) 重试前的等待时间
+func (b *Backoff) Duration(n int) time.Duration {
+	initial, max, mul, jitter := b.Initial, b.Max, b.Multiplier, b.Jitter
+	if initial <= 0 {
+		initial = DefaultBackoff.Initial
+	}
+	if max <= 0 {
+		max = DefaultBackoff.Max
+	}
+	if mul < 1 {
+		mul = DefaultBackoff.Multiplier
+	}
+	switch {
+	case jitter == 0:
+		jitter = DefaultBackoff.Jitter
+	case jitter < 0:
+		jitter = 0
+	case jitter > 1:
+		jitter = 1
+	}
+	d := float64(initial) * math.Pow(mul, float64(n))
+	if d > float64(max) {
+		d = float64(max)
+	}
+	d *= 1 + jitter*(2*rand.Float64()-1)
+	return time.Duration(d)
+}
+
+// exhausted 已失败 n 次后是否应放弃
+func (b *Backoff) exhausted(n int) bool {
+	return b.MaxAttempts > 0 && n >= b.MaxAttempts
+}
+
+// 由 driver 产生的连接生命周期元事件的 sub_type
+const (
+	LifecycleConnect    = "connect"    // 首次连接成功
+	LifecycleDisconnect = "disconnect" // 连接断开
+	LifecycleReconnect  = "reconnect"  // 断开后重新连接成功
+)
+
+// lifecycleEvent 构造 meta_event_type 为 lifecycle 的元事件
+func lifecycleEvent(selfID int64, subType string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"time":            time.Now().Unix(),
+		"self_id":         selfID,
+		"post_type":       "meta_event",
+		"meta_event_type": "lifecycle",
+		"sub_type":        subType,
+	})
+	return data
+}
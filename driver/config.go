@@ -0,0 +1,99 @@
+package driver
+
+import (
+	"time"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// 注册 zero.LoadConfig 中 driver 列表可用的类型 ws wss http
+func init() {
+	zero.RegisterDriver("ws", newWSClientFromConfig)
+	zero.RegisterDriver("wss", newWSServerFromConfig)
+	zero.RegisterDriver("http", newHTTPFromConfig)
+}
+
+// backoffConfig 配置文件中的 backoff 项
+type backoffConfig struct {
+	Initial     time.Duration `json:"initial"`
+	Max         time.Duration `json:"max"`
+	Multiplier  float64       `json:"multiplier"`
+	Jitter      float64       `json:"jitter"`
+	MaxAttempts int           `json:"max_attempts"`
+}
+
+// newWSClientFromConfig type: ws
+func newWSClientFromConfig(c zero.DriverConfig) (zero.Driver, error) {
+	var cfg struct {
+		URL         string         `json:"url"`
+		AccessToken string         `json:"access_token"`
+		Backoff     *backoffConfig `json:"backoff"`
+	}
+	if err := c.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.URL == "" {
+		return nil, c.Errorf("url", "required")
+	}
+	ws := NewWebSocketClient(cfg.URL, cfg.AccessToken)
+	if b := cfg.Backoff; b != nil {
+		ws.Backoff = Backoff(*b)
+	}
+	return ws, nil
+}
+
+// newWSServerFromConfig type: wss
+func newWSServerFromConfig(c zero.DriverConfig) (zero.Driver, error) {
+	cfg := struct {
+		URL          string `json:"url"`
+		AccessToken  string `json:"access_token"`
+		Wait         int    `json:"wait"`
+		CertFile     string `json:"cert_file"`
+		KeyFile      string `json:"key_file"`
+		ClientCAFile string `json:"client_ca_file"`
+	}{Wait: 16}
+	if err := c.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	switch {
+	case cfg.URL == "":
+		return nil, c.Errorf("url", "required")
+	case cfg.Wait <= 0:
+		return nil, c.Errorf("wait", "must be positive")
+	case (cfg.CertFile == "") != (cfg.KeyFile == ""):
+		if cfg.CertFile == "" {
+			return nil, c.Errorf("cert_file", "required when key_file is set")
+		}
+		return nil, c.Errorf("key_file", "required when cert_file is set")
+	}
+	wss := NewWebSocketServer(cfg.Wait, cfg.URL, cfg.AccessToken)
+	wss.CertFile = cfg.CertFile
+	wss.KeyFile = cfg.KeyFile
+	wss.ClientCAFile = cfg.ClientCAFile
+	return wss, nil
+}
+
+// newHTTPFromConfig type: http, 未设置 url 时仅调用 api_url
+func newHTTPFromConfig(c zero.DriverConfig) (zero.Driver, error) {
+	var cfg struct {
+		URL          string         `json:"url"`
+		APIURL       string         `json:"api_url"`
+		AccessToken  string         `json:"access_token"`
+		Secret       string         `json:"secret"`
+		QuickTimeout *time.Duration `json:"quick_timeout"`
+	}
+	if err := c.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.URL == "" {
+		if cfg.APIURL == "" {
+			return nil, c.Errorf("url", "url or api_url is required")
+		}
+		return NewHTTPCaller(cfg.APIURL, cfg.AccessToken), nil
+	}
+	hs := NewHTTPServer(cfg.URL, cfg.APIURL, cfg.AccessToken, cfg.Secret)
+	if cfg.QuickTimeout != nil {
+		hs.QuickTimeout = *cfg.QuickTimeout
+	}
+	return hs, nil
+}
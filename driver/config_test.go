@@ -0,0 +1,128 @@
+package driver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("ZB_TOKEN", "secret")
+	files := map[string]string{
+		"bot.yaml": `
+nickname: [椛椛]
+command_prefix: "/"
+super_users: [123]
+max_process_time: 2m
+length_policy: forward
+dedup:
+  window: 5s
+driver:
+  - type: ws
+    url: ws://127.0.0.1:6700
+    access_token: ${ZB_TOKEN}
+    backoff: {initial: 2s, max_attempts: 3}
+  - type: wss
+    url: ws://127.0.0.1:6701
+  - type: http
+    url: http://127.0.0.1:5701
+    api_url: http://127.0.0.1:5700
+    quick_timeout: 1s
+`,
+		"bot.toml": `
+nickname = ["椛椛"]
+command_prefix = "/"
+super_users = [123]
+max_process_time = "2m"
+length_policy = "forward"
+[dedup]
+window = "5s"
+[[driver]]
+type = "ws"
+url = "ws://127.0.0.1:6700"
+access_token = "${ZB_TOKEN}"
+backoff = { initial = "2s", max_attempts = 3 }
+[[driver]]
+type = "wss"
+url = "ws://127.0.0.1:6701"
+[[driver]]
+type = "http"
+url = "http://127.0.0.1:5701"
+api_url = "http://127.0.0.1:5700"
+quick_timeout = "1s"
+`,
+		"bot.json": `{
+	"nickname": ["椛椛"], "command_prefix": "/", "super_users": [123],
+	"max_process_time": "2m", "length_policy": "forward", "dedup": {"window": "5s"},
+	"driver": [
+		{"type": "ws", "url": "ws://127.0.0.1:6700", "access_token": "${ZB_TOKEN}", "backoff": {"initial": "2s", "max_attempts": 3}},
+		{"type": "wss", "url": "ws://127.0.0.1:6701"},
+		{"type": "http", "url": "http://127.0.0.1:5701", "api_url": "http://127.0.0.1:5700", "quick_timeout": "1s"}
+	]
+}`,
+	}
+	for name, content := range files {
+		c, err := zero.LoadConfig(writeConfig(t, name, content))
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		assert.Equal(t, []string{"椛椛"}, c.NickName, name)
+		assert.Equal(t, "/", c.CommandPrefix, name)
+		assert.Equal(t, []int64{123}, c.SuperUsers, name)
+		assert.Equal(t, 2*time.Minute, c.MaxProcessTime, name)
+		assert.Equal(t, zero.ForwardOverLength, c.LengthPolicy, name)
+		assert.Equal(t, 5*time.Second, c.Dedup.Window, name)
+		if !assert.Len(t, c.Driver, 3, name) {
+			continue
+		}
+		ws := c.Driver[0].(*WSClient)
+		assert.Equal(t, "secret", ws.AccessToken, name)
+		assert.Equal(t, 2*time.Second, ws.Backoff.Initial, name)
+		assert.Equal(t, 3, ws.Backoff.MaxAttempts, name)
+		assert.Equal(t, "ws://127.0.0.1:6701", c.Driver[1].(*WSServer).Url, name)
+		hs := c.Driver[2].(*HTTPServer)
+		assert.Equal(t, "http://127.0.0.1:5700", hs.APIUrl, name)
+		assert.Equal(t, time.Second, hs.QuickTimeout, name)
+	}
+}
+
+func TestLoadConfigError(t *testing.T) {
+	for content, field := range map[string]string{
+		"max_process_time: 1.5\n":                                                       "max_process_time",
+		"nick_name: [a]\n":                                                              "nick_name",
+		"super_users: [1, x]\n":                                                         "super_users[1]",
+		"length_policy: merge\n":                                                        "length_policy",
+		"driver:\n  - type: ws\n  - type: ws\n    url: \n":                              "driver[0].url",
+		"driver:\n  - type: ws\n    url: ws://a\n  - type: mqtt\n":                      "driver[1].type",
+		"driver:\n  - type: wss\n    url: ws://a\n    cert_file: a.pem\n":               "driver[0].key_file",
+		"driver:\n  - type: ws\n    url: ws://a\n    access_token: ${ZB_UNSET_TOKEN}\n": "driver[0].access_token",
+	} {
+		_, err := zero.LoadConfig(writeConfig(t, "bot.yml", content))
+		var cerr *zero.ConfigError
+		if assert.True(t, errors.As(err, &cerr), content) {
+			assert.Equal(t, field, cerr.Field, content)
+			assert.Contains(t, err.Error(), "bot.yml: "+field+": ", content)
+		}
+	}
+	c, err := zero.LoadConfig(writeConfig(t, "bot.yml", "max_process_time: 60\ndriver:\n  - type: http\n    url: http://a\n    api_url: http://b\n    quick_timeout: \"1\"\n"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Minute, c.MaxProcessTime) // 整数视为秒数
+		assert.Equal(t, time.Second, c.Driver[0].(*HTTPServer).QuickTimeout)
+	}
+	t.Setenv("ZB_PORT", "")
+	c, err = zero.LoadConfig(writeConfig(t, "bot.yml", "driver:\n  - type: ws\n    url: ws://127.0.0.1:${ZB_PORT:-6700}/$${x}\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ws://127.0.0.1:6700/${x}", c.Driver[0].(*WSClient).Url)
+}
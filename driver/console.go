@@ -0,0 +1,304 @@
+package driver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// consoleHelp 控制台指令说明
+const consoleHelp = `控制台指令:
+  :group <群号>    切换到群聊, 群号为 0 时切换到私聊
+  :private         切换到私聊
+  :user <QQ号>     设置发送者
+  :role <owner|admin|member>  设置群内身份
+  :status          查看当前设置
+  :help            显示本帮助
+消息中可直接使用 CQ 码, 另有简写:
+  @<QQ号>          [CQ:at,qq=<QQ号>], @bot 为机器人自身, @all 为全体成员
+  [img:<文件>]     [CQ:image,file=<文件>]
+`
+
+var (
+	consoleAt    = regexp.MustCompile(`@(\d+|bot|all)\b`)
+	consoleImage = regexp.MustCompile(`\[img:([^\]]+)\]`)
+)
+
+// Console 控制台驱动, 将标准输入的每一行作为消息事件, 发送的消息以 CQ 码打印到标准输出
+//
+//	用于在没有 OneBot 实现的情况下本地调试插件
+type Console struct {
+	SelfID   int64
+	GroupID  int64  // 为 0 时发送私聊消息
+	UserID   int64  // 发送者
+	Role     string // 发送者的群内身份
+	Nickname string // 发送者昵称
+
+	In  io.Reader
+	Out io.Writer
+
+	mu     sync.Mutex // 设置与输出锁
+	msgid  int32
+	closed uint32
+	once   sync.Once
+	done   chan struct{} // Close 时关闭
+}
+
+// NewConsole 创建账号为 selfID 的控制台驱动, 默认以 10000 的身份私聊
+func NewConsole(selfID int64) *Console {
+	return &Console{
+		SelfID:   selfID,
+		UserID:   10000,
+		Role:     "member",
+		Nickname: "console",
+		In:       os.Stdin,
+		Out:      os.Stdout,
+	}
+}
+
+// Connect 添加到 APICallers
+func (c *Console) Connect() {
+	zero.APICallers.Store(c.SelfID, c)
+	c.printf("[console] 已启动, 输入 :help 查看指令\n")
+}
+
+// Listen 逐行读取输入并交给 handler, 输入结束或 Close 后返回
+func (c *Console) Listen(handler func([]byte, zero.APICaller)) {
+	done := c.doneChan()
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		s := bufio.NewScanner(c.In)
+		for s.Scan() {
+			select {
+			case lines <- s.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+	for {
+		var line string
+		select {
+		case <-done:
+			return
+		case l, ok := <-lines:
+			if !ok {
+				return
+			}
+			line = strings.TrimSpace(l)
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			c.command(line[1:])
+			continue
+		}
+		handler(c.event(line), c)
+	}
+}
+
+// Close 使 Listen 立即返回并从 APICallers 中删除
+//
+//	读取 In 的协程无法被打断, 将在读到下一行或输入结束后退出;
+//	如需立即释放, 可在 Close 后关闭 In (如 os.Stdin)
+func (c *Console) Close() error {
+	if atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		close(c.doneChan())
+	}
+	zero.APICallers.Delete(c.SelfID)
+	return nil
+}
+
+func (c *Console) doneChan() chan struct{} {
+	c.once.Do(func() { c.done = make(chan struct{}) })
+	return c.done
+}
+
+// command 处理控制台指令
+func (c *Console) command(line string) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch name {
+	case "group", "g":
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			fmt.Fprintf(c.Out, "[console] 无效的群号: %v\n", arg)
+			return
+		}
+		c.GroupID = id
+	case "private", "p":
+		c.GroupID = 0
+	case "user", "u":
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			fmt.Fprintf(c.Out, "[console] 无效的QQ号: %v\n", arg)
+			return
+		}
+		c.UserID = id
+	case "role", "r":
+		switch arg {
+		case "owner", "admin", "member":
+			c.Role = arg
+		default:
+			fmt.Fprintf(c.Out, "[console] 无效的身份: %v\n", arg)
+			return
+		}
+	case "status", "s":
+	case "help", "h":
+		io.WriteString(c.Out, consoleHelp)
+		return
+	default:
+		fmt.Fprintf(c.Out, "[console] 未知指令: %v, 输入 :help 查看指令\n", name)
+		return
+	}
+	if c.GroupID == 0 {
+		fmt.Fprintf(c.Out, "[console] 私聊, 用户 %v\n", c.UserID)
+	} else {
+		fmt.Fprintf(c.Out, "[console] 群 %v, 用户 %v (%v)\n", c.GroupID, c.UserID, c.Role)
+	}
+}
+
+// expand 将简写展开为 CQ 码
+func (c *Console) expand(line string) string {
+	line = consoleImage.ReplaceAllStringFunc(line, func(s string) string {
+		return message.Image(consoleImage.FindStringSubmatch(s)[1]).CQCode()
+	})
+	return consoleAt.ReplaceAllStringFunc(line, func(s string) string {
+		switch s[1:] {
+		case "bot":
+			return message.At(c.SelfID).CQCode()
+		case "all":
+			return message.AtAll().CQCode()
+		}
+		return "[CQ:at,qq=" + s[1:] + "]"
+	})
+}
+
+// event 构造消息事件
+func (c *Console) event(line string) []byte {
+	raw := c.expand(line)
+	c.mu.Lock()
+	e := zero.H{
+		"time":        time.Now().Unix(),
+		"self_id":     c.SelfID,
+		"post_type":   "message",
+		"message_id":  atomic.AddInt32(&c.msgid, 1),
+		"user_id":     c.UserID,
+		"message":     raw,
+		"raw_message": raw,
+		"font":        0,
+		"sender": zero.H{
+			"user_id":  c.UserID,
+			"nickname": c.Nickname,
+		},
+	}
+	if c.GroupID == 0 {
+		e["message_type"] = "private"
+		e["sub_type"] = "friend"
+	} else {
+		e["message_type"] = "group"
+		e["sub_type"] = "normal"
+		e["group_id"] = c.GroupID
+		e["sender"].(zero.H)["role"] = c.Role
+	}
+	c.mu.Unlock()
+	data, _ := json.Marshal(e)
+	return data
+}
+
+func (c *Console) printf(format string, a ...interface{}) {
+	c.mu.Lock()
+	fmt.Fprintf(c.Out, format, a...)
+	c.mu.Unlock()
+}
+
+// CallApi 打印发送的消息, 其余 API 返回空数据
+func (c *Console) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
+	var data interface{}
+	switch req.Action {
+	case "send_msg", "send_group_msg", "send_private_msg":
+		target := "私聊 " + fmt.Sprint(req.Params["user_id"])
+		if gid, ok := req.Params["group_id"]; ok && fmt.Sprint(gid) != "0" {
+			target = "群 " + fmt.Sprint(gid)
+		}
+		c.printf("[%v] bot: %v\n", target, consoleMessage(req.Params["message"]).CQCode())
+		data = zero.H{"message_id": atomic.AddInt32(&c.msgid, 1)}
+	case "send_group_forward_msg", "send_private_forward_msg":
+		for _, node := range consoleMessage(req.Params["messages"]) {
+			c.printf("[合并转发] %v: %v\n", node.Data["name"], node.Data["content"])
+		}
+		data = zero.H{"message_id": atomic.AddInt32(&c.msgid, 1)}
+	case "get_login_info":
+		data = zero.H{"user_id": c.SelfID, "nickname": "bot"}
+	case "get_stranger_info", "get_group_member_info":
+		c.mu.Lock()
+		info := zero.H{"user_id": c.UserID, "nickname": c.Nickname, "role": c.Role}
+		c.mu.Unlock()
+		if uid, ok := req.Params["user_id"]; ok { // 未指定时为当前发送者
+			info["user_id"] = uid
+		}
+		if gid, ok := req.Params["group_id"]; ok {
+			info["group_id"] = gid
+		}
+		data = info
+	default:
+		c.printf("[console] 调用 %v %v\n", req.Action, consoleParams(req.Params))
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nullResponse, err
+	}
+	return zero.APIResponse{
+		Status: "ok",
+		Data:   gjson.ParseBytes(raw),
+		Echo:   req.Echo,
+	}, nil
+}
+
+// CallApiContext 同 CallApi
+func (c *Console) CallApiContext(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nullResponse, err
+	}
+	return c.CallApi(req)
+}
+
+// consoleMessage 将 API 参数中的消息转换为 message.Message
+func consoleMessage(v interface{}) message.Message {
+	switch m := v.(type) {
+	case message.Message:
+		return m
+	case *message.Message:
+		return *m
+	case message.MessageSegment:
+		return message.Message{m}
+	case string:
+		return message.ParseMessageFromString(m)
+	default:
+		data, _ := json.Marshal(v)
+		return message.ParseMessage(data)
+	}
+}
+
+func consoleParams(p zero.Params) string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
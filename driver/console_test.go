@@ -0,0 +1,66 @@
+package driver
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+func TestConsole(t *testing.T) {
+	out := new(bytes.Buffer)
+	c := NewConsole(100)
+	c.In = strings.NewReader("hello\n:group 1\n:user 2\n:role admin\n@bot hi [img:a.png]\n")
+	c.Out = out
+	var events []gjson.Result
+	c.Listen(func(data []byte, caller zero.APICaller) {
+		events = append(events, gjson.ParseBytes(data))
+	})
+	assert.Len(t, events, 2)
+	assert.Equal(t, "private", events[0].Get("message_type").String())
+	assert.Equal(t, int64(10000), events[0].Get("user_id").Int())
+	assert.Equal(t, "group", events[1].Get("message_type").String())
+	assert.Equal(t, int64(1), events[1].Get("group_id").Int())
+	assert.Equal(t, int64(2), events[1].Get("user_id").Int())
+	assert.Equal(t, "admin", events[1].Get("sender.role").String())
+	assert.Equal(t, "[CQ:at,qq=100] hi [CQ:image,file=a.png]", events[1].Get("message").String())
+
+	rsp, err := c.CallApi(zero.APIRequest{Action: "send_group_msg", Params: zero.Params{
+		"group_id": 1,
+		"message":  message.Message{message.Text("pong"), message.At(2)},
+	}})
+	assert.NoError(t, err)
+	assert.True(t, rsp.Data.Get("message_id").Exists())
+	assert.Contains(t, out.String(), "[群 1] bot: pong[CQ:at,qq=2]")
+}
+
+func TestConsoleInfoAndClose(t *testing.T) {
+	c := NewConsole(100)
+	c.Nickname = `a"b`
+	rsp, err := c.CallApi(zero.APIRequest{Action: "get_group_member_info", Params: zero.Params{"group_id": 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10000), rsp.Data.Get("user_id").Int()) // 未指定时为当前发送者
+	assert.Equal(t, `a"b`, rsp.Data.Get("nickname").String())
+
+	r, w := io.Pipe()
+	defer w.Close()
+	c.In = r
+	done := make(chan struct{})
+	go func() {
+		c.Listen(func([]byte, zero.APICaller) {})
+		close(done)
+	}()
+	assert.NoError(t, c.Close())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close 后 Listen 未返回")
+	}
+}
@@ -0,0 +1,65 @@
+package driver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// HeartbeatMissLimit 连续错过多少个心跳间隔后认为连接已失效并断开, 为 0 时不检测
+var HeartbeatMissLimit = 3
+
+// watchdog 心跳看门狗, 收到第一个心跳后开始计时, 超时调用 dead
+type watchdog struct {
+	mu   sync.Mutex
+	t    *time.Timer
+	dead func()
+}
+
+// beat 收到心跳, 重新计时
+func (w *watchdog) beat(interval time.Duration) {
+	if HeartbeatMissLimit <= 0 || interval <= 0 {
+		return
+	}
+	d := interval * time.Duration(HeartbeatMissLimit)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.t == nil {
+		w.t = time.AfterFunc(d, w.dead)
+		return
+	}
+	w.t.Reset(d)
+}
+
+// stop 停止计时, 下次心跳时重新开始
+func (w *watchdog) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.t != nil {
+		w.t.Stop()
+		w.t = nil
+	}
+}
+
+// handleHeartbeat 处理 OneBot 11/12 心跳元事件, 返回 rsp 是否为心跳
+//
+//	https://github.com/botuniverse/onebot-11/blob/master/event/meta.md#%E5%BF%83%E8%B7%B3
+//	https://12.onebot.dev/interface/meta/events/#metaheartbeat
+func handleHeartbeat(rsp gjson.Result, selfID int64, bots *v12Bots, w *watchdog) bool {
+	switch {
+	case rsp.Get("meta_event_type").Str == "heartbeat":
+		interval := time.Duration(rsp.Get("interval").Int()) * time.Millisecond
+		w.beat(interval)
+		zero.ReportHeartbeat(selfID, interval, rsp.Get("status"))
+		return true
+	case rsp.Get("type").Str == "meta" && rsp.Get("detail_type").Str == "heartbeat":
+		interval := time.Duration(rsp.Get("interval").Int()) * time.Millisecond
+		w.beat(interval)
+		bots.heartbeat(interval)
+		return true
+	}
+	return false
+}
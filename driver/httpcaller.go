@@ -0,0 +1,198 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/utils/helper"
+)
+
+// ErrEmptyAPIUrl 未设置 HTTP API 地址
+var ErrEmptyAPIUrl = errors.New("empty http api url")
+
+// httpStatusError HTTP API 返回了非 200 状态码
+type httpStatusError int
+
+func (e httpStatusError) Error() string {
+	return "http api returned status " + strconv.Itoa(int(e))
+}
+
+// HTTPCaller 使用 HTTP API 调用 OneBot 实现, 仅能调用 API, 不能接收事件
+type HTTPCaller struct {
+	seq         uint64
+	client      http.Client
+	address     string
+	Url         string // HTTP API 地址
+	AccessToken string
+	selfID      int64
+	v12         bool    // 是否使用 OneBot 12 协议
+	bots        v12Bots // OneBot 12 实现上的机器人
+}
+
+// NewHTTPCaller 使用 HTTP API 通信
+func NewHTTPCaller(url, accessToken string) *HTTPCaller {
+	hc := &HTTPCaller{
+		Url:         url,
+		AccessToken: accessToken,
+	}
+	hc.init()
+	return hc
+}
+
+func (hc *HTTPCaller) init() {
+	network, address := resolveURI(hc.Url)
+	hc.address = strings.TrimSuffix(address, "/")
+	hc.client = http.Client{
+		Timeout: time.Minute,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				if network == "unix" {
+					host, _, err := net.SplitHostPort(addr)
+					if err != nil {
+						host = addr
+					}
+					filepath, err := base64.RawURLEncoding.DecodeString(host)
+					if err == nil {
+						addr = helper.BytesToString(filepath)
+					}
+				}
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr) // support unix socket transport
+			},
+		},
+	}
+}
+
+// Connect 获取账号信息并添加到 APICallers
+func (hc *HTTPCaller) Connect() {
+	if hc.address == "" {
+		hc.init()
+	}
+	log.Infof("[httpapi] 开始尝试连接到HTTP API: %v", hc.Url)
+	for {
+		rsp, err := hc.CallApi(zero.APIRequest{
+			Action: "get_login_info",
+		})
+		if e, ok := err.(httpStatusError); ok && e == http.StatusNotFound && !hc.v12 {
+			// OneBot 12 的 HTTP 动作请求统一发往根路径
+			hc.v12 = true
+			rsp, err = hc.CallApi(zero.APIRequest{Action: "get_version"})
+			if err == nil && rsp.Data.Get("onebot_version").String() == "12" {
+				hc.bots.fetch(hc)
+				log.Infof("[httpapi] 连接HTTP API: %s 成功, 协议: OneBot 12", hc.Url)
+				return
+			}
+			hc.v12 = false
+		}
+		if err != nil {
+			log.Warnf("[httpapi] 连接到HTTP API %v 时出现错误: %v", hc.Url, err)
+			time.Sleep(2 * time.Second) // 等待两秒后重新连接
+			continue
+		}
+		hc.selfID = rsp.Data.Get("user_id").Int()
+		zero.APICallers.Store(hc.selfID, hc) // 添加Caller到 APICaller list...
+		log.Infof("[httpapi] 连接HTTP API: %s 成功, 账号: %d", hc.Url, hc.selfID)
+		return
+	}
+}
+
+// Listen HTTP API 不接收事件, 直接返回
+func (hc *HTTPCaller) Listen(_ func([]byte, zero.APICaller)) {}
+
+// Close 从 APICallers 中删除并关闭空闲连接
+func (hc *HTTPCaller) Close() error {
+	if hc.v12 {
+		hc.bots.clear()
+	} else if hc.selfID != 0 {
+		zero.APICallers.Delete(hc.selfID)
+	}
+	hc.client.CloseIdleConnections()
+	return nil
+}
+
+// SelfID 获得 bot qq 号
+func (hc *HTTPCaller) SelfID() int64 {
+	return hc.selfID
+}
+
+func (hc *HTTPCaller) nextSeq() uint64 {
+	return atomic.AddUint64(&hc.seq, 1)
+}
+
+// CallApi 发送 HTTP 请求到 /{action}
+func (hc *HTTPCaller) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
+	return hc.CallApiContext(context.Background(), req)
+}
+
+// CallApiContext 发送 HTTP 请求到 /{action}, ctx 取消时中止请求
+func (hc *HTTPCaller) CallApiContext(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	if hc.Url == "" {
+		return nullResponse, ErrEmptyAPIUrl
+	}
+	if hc.address == "" {
+		hc.init()
+	}
+	req.Echo = hc.nextSeq()
+	if req.Params == nil {
+		req.Params = zero.Params{}
+	}
+	var body []byte
+	var err error
+	endpoint := hc.address + "/" + req.Action
+	if hc.v12 { // https://12.onebot.dev/connect/communication/http/#_3
+		endpoint = hc.address
+		body, err = json.Marshal(&req)
+	} else {
+		body, err = json.Marshal(req.Params)
+	}
+	if err != nil {
+		return nullResponse, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nullResponse, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "ZeroBot/1.6.3")
+	if hc.AccessToken != "" {
+		request.Header.Set("Authorization", "Bearer "+hc.AccessToken)
+	}
+	log.Debug("[httpapi] 向服务器发送请求: ", &req)
+	resp, err := hc.client.Do(request)
+	if err != nil {
+		log.Warn("[httpapi] 向HTTP API发送请求失败: ", err.Error())
+		return nullResponse, err
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nullResponse, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nullResponse, httpStatusError(resp.StatusCode)
+	}
+	log.Debug("[httpapi] 接收到API调用返回: ", strings.TrimSpace(helper.BytesToString(payload)))
+	rsp := gjson.Parse(helper.BytesToString(payload))
+	return zero.APIResponse{
+		Status:  rsp.Get("status").String(),
+		Data:    rsp.Get("data"),
+		Msg:     rsp.Get("msg").Str,
+		Wording: rsp.Get("wording").Str,
+		RetCode: rsp.Get("retcode").Int(),
+		Echo:    req.Echo,
+	}, nil
+}
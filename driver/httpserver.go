@@ -0,0 +1,301 @@
+package driver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/utils/helper"
+)
+
+// HTTPServer 接收 OneBot 实现通过 HTTP POST 上报的事件
+type HTTPServer struct {
+	Url          string        // 监听地址
+	APIUrl       string        // HTTP API 地址, 用于调用 API
+	AccessToken  string        // HTTP API 的 AccessToken
+	Secret       string        // 上报签名密钥, 用于校验 X-Signature
+	QuickTimeout time.Duration // 等待快速操作的最长时间, 超时后快速操作将通过 API 发送; 事件处理结束后立即响应
+	lstn         net.Listener
+	mu           sync.Mutex
+	callers      map[int64]*HTTPCaller
+	v12          *HTTPCaller // OneBot 12 的 HTTP API, 由各机器人共用
+	closed       uint32      // 已调用 Close
+}
+
+// NewHTTPServer 使用 HTTP POST 接收事件, 使用 HTTP API 调用
+//
+//	secret 为空则不校验上报签名
+func NewHTTPServer(url, apiurl, accessToken, secret string) *HTTPServer {
+	return &HTTPServer{
+		Url:          url,
+		APIUrl:       apiurl,
+		AccessToken:  accessToken,
+		Secret:       secret,
+		QuickTimeout: time.Second * 4,
+	}
+}
+
+// Connect 监听 http 服务
+func (hs *HTTPServer) Connect() {
+	network, address := resolveURI(hs.Url)
+	uri, err := url.Parse(address)
+	if err == nil && uri.Scheme != "" {
+		address = uri.Host
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		log.Warn("[http] HTTP服务器监听失败:", err)
+		hs.lstn = nil
+		return
+	}
+
+	hs.lstn = listener
+	log.Infoln("[http] HTTP服务器开始监听:", listener.Addr())
+}
+
+// Listen 开始监听事件
+func (hs *HTTPServer) Listen(handler func([]byte, zero.APICaller)) {
+	mux := http.ServeMux{}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		hs.any(w, r, handler)
+	})
+	for atomic.LoadUint32(&hs.closed) == 0 {
+		if hs.lstn == nil {
+			time.Sleep(time.Millisecond * time.Duration(3))
+			hs.Connect()
+			continue
+		}
+		log.Infof("[http] HTTP 服务器开始处理: %v", hs.lstn.Addr())
+		err := http.Serve(hs.lstn, &mux)
+		if atomic.LoadUint32(&hs.closed) != 0 {
+			log.Infoln("[http] 已关闭HTTP服务器:", hs.Url)
+			return
+		}
+		if err != nil {
+			log.Warn("[http] HTTP服务器在端点", hs.lstn.Addr(), "失败:", err)
+			hs.lstn = nil
+		}
+	}
+}
+
+// Close 停止监听, Listen 将返回
+func (hs *HTTPServer) Close() error {
+	if !atomic.CompareAndSwapUint32(&hs.closed, 0, 1) {
+		return nil
+	}
+	hs.mu.Lock()
+	for id, c := range hs.callers {
+		if id != 0 {
+			zero.APICallers.Delete(id)
+		}
+		c.client.CloseIdleConnections()
+	}
+	hs.callers = nil
+	if hs.v12 != nil {
+		_ = hs.v12.Close()
+	}
+	hs.mu.Unlock()
+	if hs.lstn == nil {
+		return nil
+	}
+	return hs.lstn.Close()
+}
+
+func checkSignature(req *http.Request, body []byte, secret string) int {
+	if secret == "" { // quick path
+		return http.StatusOK
+	}
+
+	sig := req.Header.Get("X-Signature")
+	if sig == "" {
+		return http.StatusUnauthorized
+	}
+	sig = strings.TrimPrefix(sig, "sha1=")
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return http.StatusForbidden
+	}
+	mac := hmac.New(sha1.New, helper.StringToBytes(secret))
+	_, _ = mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return http.StatusForbidden
+	}
+	return http.StatusOK
+}
+
+// caller 获取 selfID 对应的 HTTPCaller, 首次上报时添加到 APICallers
+//
+//	上报中没有 self_id 时, 返回的 caller 不添加到 APICallers
+func (hs *HTTPServer) caller(selfID int64) *HTTPCaller {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if hs.callers == nil {
+		hs.callers = make(map[int64]*HTTPCaller, 4)
+	}
+	c, ok := hs.callers[selfID]
+	if !ok {
+		c = NewHTTPCaller(hs.APIUrl, hs.AccessToken)
+		c.selfID = selfID
+		hs.callers[selfID] = c
+		if selfID == 0 {
+			log.Warnln("[http] 接收到的HTTP上报中没有 self_id, 不添加到 APICallers")
+			return c
+		}
+		zero.APICallers.Store(selfID, c) // 添加Caller到 APICaller list...
+		log.Infof("[http] 接收到HTTP上报, 账号: %d", selfID)
+	}
+	return c
+}
+
+func (hs *HTTPServer) any(w http.ResponseWriter, r *http.Request, handler func([]byte, zero.APICaller)) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Warnf("[http] 读取 %v 的上报时出现错误: %v", r.RemoteAddr, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	status := checkSignature(r, payload, hs.Secret)
+	if status != http.StatusOK {
+		log.Warnf("[http] 已拒绝 %v 的上报: 签名校验失败(code:%d)", r.RemoteAddr, status)
+		w.WriteHeader(status)
+		return
+	}
+	rsp := gjson.Parse(helper.BytesToString(payload))
+	if r.Header.Get("X-OneBot-Version") == "12" || rsp.Get("detail_type").Exists() {
+		hs.v12event(w, rsp, payload, handler)
+		return
+	}
+	selfID, err := strconv.ParseInt(r.Header.Get("X-Self-ID"), 10, 64)
+	if err != nil {
+		selfID = rsp.Get("self_id").Int()
+	}
+	c := hs.caller(selfID)
+	if rsp.Get("meta_event_type").Str == "heartbeat" { // 心跳仅上报状态, 不再向上分发
+		if selfID != 0 {
+			zero.ReportHeartbeat(selfID, time.Duration(rsp.Get("interval").Int())*time.Millisecond, rsp.Get("status"))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	log.Debug("[http] 接收到事件: ", helper.BytesToString(payload))
+	qc := &httpQuickCaller{HTTPCaller: c, op: make(chan zero.Params, 1), done: make(chan struct{})}
+	handler(payload, qc)
+
+	// 等待快速操作, 事件处理结束后不再等待
+	var op zero.Params
+	t := time.NewTimer(hs.QuickTimeout)
+	defer t.Stop()
+	select {
+	case op = <-qc.op:
+	case <-qc.done:
+		op = qc.close()
+	case <-t.C:
+		op = qc.close()
+	}
+	if op == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	data, err := json.Marshal(op)
+	if err != nil {
+		log.Warn("[http] 序列化快速操作失败: ", err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	log.Debug("[http] 返回快速操作: ", helper.BytesToString(data))
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// v12event 处理 OneBot 12 的 Webhook 上报, 不支持快速操作
+// https://12.onebot.dev/connect/communication/http-webhook/
+func (hs *HTTPServer) v12event(w http.ResponseWriter, rsp gjson.Result, payload []byte, handler func([]byte, zero.APICaller)) {
+	hs.mu.Lock()
+	if hs.v12 == nil {
+		hs.v12 = NewHTTPCaller(hs.APIUrl, hs.AccessToken)
+		hs.v12.v12 = true
+	}
+	hs.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+	c := hs.v12.bots.handle(hs.v12, rsp)
+	if c == nil { // 忽略元事件
+		return
+	}
+	log.Debug("[http] 接收到事件: ", helper.BytesToString(payload))
+	handler(payload, c)
+}
+
+// httpQuickCaller 在上报请求返回前, 将快速操作写入响应体
+type httpQuickCaller struct {
+	*HTTPCaller
+	mu     sync.Mutex
+	closed bool
+	op     chan zero.Params
+	done   chan struct{}
+	once   sync.Once
+}
+
+// EventFinished 事件处理结束, 上报请求不再等待快速操作
+func (qc *httpQuickCaller) EventFinished() {
+	qc.once.Do(func() { close(qc.done) })
+}
+
+// close 停止接收快速操作, 返回关闭前已提交的快速操作
+func (qc *httpQuickCaller) close() zero.Params {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	qc.closed = true
+	select {
+	case op := <-qc.op:
+		return op
+	default:
+		return nil
+	}
+}
+
+// CallApi 在响应上报前调用 .handle_quick_operation 将通过响应体返回, 其余请求使用 HTTP API
+func (qc *httpQuickCaller) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
+	return qc.CallApiContext(context.Background(), req)
+}
+
+// CallApiContext 同 CallApi
+func (qc *httpQuickCaller) CallApiContext(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	if req.Action == ".handle_quick_operation" {
+		var op zero.Params
+		switch o := req.Params["operation"].(type) {
+		case zero.Params:
+			op = o
+		case map[string]interface{}:
+			op = o
+		}
+		qc.mu.Lock()
+		if !qc.closed && op != nil {
+			qc.closed = true
+			qc.op <- op
+			qc.mu.Unlock()
+			return zero.APIResponse{Status: "ok", Data: gjson.Parse("null")}, nil
+		}
+		qc.mu.Unlock()
+	}
+	return qc.HTTPCaller.CallApiContext(ctx, req)
+}
@@ -0,0 +1,81 @@
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+func TestCheckSignature(t *testing.T) {
+	body := []byte(`{"post_type":"message"}`)
+	newReq := func(sig string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+		if sig != "" {
+			r.Header.Set("X-Signature", sig)
+		}
+		return r
+	}
+	assert.Equal(t, http.StatusOK, checkSignature(newReq(""), body, ""))
+	assert.Equal(t, http.StatusUnauthorized, checkSignature(newReq(""), body, "secret"))
+	assert.Equal(t, http.StatusForbidden, checkSignature(newReq("sha1=0000"), body, "secret"))
+	assert.Equal(t, http.StatusForbidden, checkSignature(newReq("sha1=zz"), body, "secret"))
+	assert.Equal(t, http.StatusOK, checkSignature(newReq("sha1=337a43569f539f97888127f17fefc625794e5e06"), body, "secret"))
+}
+
+func TestHTTPServerQuickOperation(t *testing.T) {
+	hs := NewHTTPServer("http://127.0.0.1:0", "http://127.0.0.1:0", "", "")
+	hs.QuickTimeout = time.Minute
+	defer hs.Close()
+	post := func(handler func([]byte, zero.APICaller)) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"post_type":"message","self_id":1}`))
+		done := make(chan struct{})
+		go func() {
+			hs.any(w, r, handler)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("上报请求未在处理结束后返回")
+		}
+		return w
+	}
+
+	w := post(func(_ []byte, c zero.APICaller) { // 无快速操作
+		go c.(zero.EventFinisher).EventFinished()
+	})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = post(func(_ []byte, c zero.APICaller) {
+		go func() {
+			_, _ = c.CallApi(zero.APIRequest{Action: ".handle_quick_operation",
+				Params: zero.Params{"operation": zero.Params{"reply": "hi"}}})
+			c.(zero.EventFinisher).EventFinished()
+		}()
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"reply":"hi"}`, w.Body.String())
+}
+
+func TestHTTPServerNoSelfID(t *testing.T) {
+	hs := NewHTTPServer("http://127.0.0.1:0", "http://127.0.0.1:0", "", "")
+	defer hs.Close()
+	var got zero.APICaller
+	w := httptest.NewRecorder()
+	hs.any(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"post_type":"message"}`)),
+		func(_ []byte, c zero.APICaller) {
+			got = c
+			c.(zero.EventFinisher).EventFinished()
+		})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NotNil(t, got) // 仍分发事件
+	_, ok := zero.APICallers.Load(0)
+	assert.False(t, ok)
+}
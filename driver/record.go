@@ -0,0 +1,193 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// Record 录制文件中的一行, 为收到的事件或一次 API 调用
+type Record struct {
+	Time     time.Time        `json:"time"`
+	Type     string           `json:"type"` // event 或 api
+	SelfID   int64            `json:"self_id"`
+	Version  int              `json:"version,omitempty"` // OneBot 协议版本, 11 时省略
+	Event    json.RawMessage  `json:"event,omitempty"`
+	Request  *zero.APIRequest `json:"request,omitempty"`
+	Response *RecordResponse  `json:"response,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// RecordResponse 录制的 API 返回
+type RecordResponse struct {
+	Status  string          `json:"status"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Msg     string          `json:"msg,omitempty"`
+	Wording string          `json:"wording,omitempty"`
+	RetCode int64           `json:"retcode"`
+}
+
+func newRecordResponse(rsp *zero.APIResponse) *RecordResponse {
+	r := &RecordResponse{
+		Status:  rsp.Status,
+		Msg:     rsp.Msg,
+		Wording: rsp.Wording,
+		RetCode: rsp.RetCode,
+	}
+	if rsp.Data.Raw != "" {
+		r.Data = json.RawMessage(rsp.Data.Raw)
+	}
+	return r
+}
+
+func (r *RecordResponse) apiResponse(echo uint64) zero.APIResponse {
+	return zero.APIResponse{
+		Status:  r.Status,
+		Data:    gjson.ParseBytes(r.Data),
+		Msg:     r.Msg,
+		Wording: r.Wording,
+		RetCode: r.RetCode,
+		Echo:    echo,
+	}
+}
+
+// eventSelfID 获取事件的机器人 ID, 兼容 OneBot 12
+func eventSelfID(data []byte) int64 {
+	if id := gjson.GetBytes(data, "self_id"); id.Exists() {
+		return id.Int()
+	}
+	return zero.ParseV12ID(gjson.GetBytes(data, "self.user_id").String())
+}
+
+func callerVersion(caller zero.APICaller) int {
+	if v, ok := caller.(zero.ProtocolVersioner); ok && v.OneBotVersion() != zero.OneBotV11 {
+		return v.OneBotVersion()
+	}
+	return 0
+}
+
+// Recorder 包装任意 Driver, 将收到的事件与 API 调用及其返回逐行写入 JSONL 文件, 可由 Replayer 回放
+//
+//	zero.Run(&zero.Config{Driver: []zero.Driver{driver.NewRecorder(ws, "record.jsonl")}})
+type Recorder struct {
+	zero.Driver
+	Path string
+
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewRecorder 录制 d 的事件与 API 调用到 path, 文件已存在时追加
+func NewRecorder(d zero.Driver, path string) *Recorder {
+	return &Recorder{Driver: d, Path: path}
+}
+
+// Connect 打开录制文件并连接被包装的 Driver
+func (r *Recorder) Connect() {
+	r.mu.Lock()
+	if r.file == nil {
+		f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Errorf("[record] 打开录制文件 %v 失败: %v", r.Path, err)
+		} else {
+			r.file = f
+			r.enc = json.NewEncoder(f)
+			log.Infof("[record] 开始录制到 %v", r.Path)
+		}
+	}
+	r.mu.Unlock()
+	r.Driver.Connect()
+}
+
+// Listen 开始监听事件, 交给 handler 的 APICaller 将录制所有调用
+func (r *Recorder) Listen(handler func([]byte, zero.APICaller)) {
+	r.Driver.Listen(func(data []byte, caller zero.APICaller) {
+		selfID := eventSelfID(data)
+		rc := &recordCaller{r: r, selfID: selfID, caller: caller}
+		// 替换 APICallers 中的同一 caller, 使 GetBot 等发起的调用也被录制
+		if v, ok := zero.APICallers.Load(selfID); ok && v == caller {
+			zero.APICallers.Store(selfID, rc)
+		}
+		r.write(&Record{
+			Time:    time.Now(),
+			Type:    "event",
+			SelfID:  selfID,
+			Version: callerVersion(caller),
+			Event:   append(json.RawMessage(nil), data...),
+		})
+		handler(data, rc)
+	})
+}
+
+// Close 关闭被包装的 Driver 与录制文件
+func (r *Recorder) Close() error {
+	err := r.Driver.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file != nil {
+		if cerr := r.file.Close(); err == nil {
+			err = cerr
+		}
+		r.file, r.enc = nil, nil
+	}
+	return err
+}
+
+func (r *Recorder) write(rec *Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.enc == nil {
+		return
+	}
+	if err := r.enc.Encode(rec); err != nil {
+		log.Warnf("[record] 写入录制文件失败: %v", err)
+	}
+}
+
+// recordCaller 录制经过的 API 调用
+type recordCaller struct {
+	r      *Recorder
+	selfID int64
+	caller zero.APICaller
+}
+
+// CallApi 调用并录制
+func (c *recordCaller) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
+	return c.CallApiContext(context.Background(), req)
+}
+
+// CallApiContext 调用并录制
+func (c *recordCaller) CallApiContext(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	start := time.Now()
+	rsp, err := c.caller.CallApiContext(ctx, req)
+	rec := &Record{
+		Time:    start,
+		Type:    "api",
+		SelfID:  c.selfID,
+		Version: callerVersion(c.caller),
+		Request: &req,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		rec.Response = newRecordResponse(&rsp)
+	}
+	c.r.write(rec)
+	return rsp, err
+}
+
+// OneBotVersion 与被包装的 caller 一致
+func (c *recordCaller) OneBotVersion() int {
+	if v, ok := c.caller.(zero.ProtocolVersioner); ok {
+		return v.OneBotVersion()
+	}
+	return zero.OneBotV11
+}
@@ -0,0 +1,53 @@
+package driver
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/zerotest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.jsonl")
+	bot := zerotest.New(10001)
+	bot.Respond("get_group_info", zero.H{"group_id": 1, "group_name": "test"})
+
+	handle := func(data []byte, caller zero.APICaller) (string, error) {
+		rsp, err := caller.CallApi(zero.APIRequest{Action: "get_group_info", Params: zero.Params{"group_id": 1}})
+		return rsp.Data.Get("group_name").String(), err
+	}
+
+	r := NewRecorder(bot, path)
+	r.Connect()
+	go r.Listen(func(data []byte, caller zero.APICaller) {
+		name, err := handle(data, caller)
+		assert.NoError(t, err)
+		assert.Equal(t, "test", name)
+	})
+	bot.SendGroupText(1, 2, "hello")
+	bot.SendGroupText(1, 2, "world")
+	assert.NoError(t, r.Close())
+
+	rp, err := NewReplayer(path, 0)
+	assert.NoError(t, err)
+	rp.Connect()
+	var texts []string
+	rp.Listen(func(data []byte, caller zero.APICaller) {
+		e := zero.Event{}
+		assert.NoError(t, json.Unmarshal(data, &e))
+		texts = append(texts, e.RawMessage)
+		name, err := handle(data, caller)
+		assert.NoError(t, err)
+		assert.Equal(t, "test", name)
+	})
+	<-rp.Done()
+	assert.Equal(t, []string{"hello", "world"}, texts)
+
+	_, err = rp.callers[10001].CallApi(zero.APIRequest{Action: "get_login_info"})
+	assert.ErrorIs(t, err, ErrNoRecord)
+	assert.NoError(t, rp.Close())
+}
@@ -0,0 +1,205 @@
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// ErrNoRecord 回放时找不到对应 action 的录制
+var ErrNoRecord = errors.New("no recorded response")
+
+// Replayer 回放 Recorder 录制的文件, 事件按录制顺序交给 zero 处理, API 调用以录制的返回应答
+type Replayer struct {
+	// Speed 回放速度倍率, 1 为原速, 2 为两倍速, 0 为不等待
+	Speed float64
+
+	events  []*Record
+	mu      sync.Mutex
+	apis    map[int64]map[string][]*replayAPI // self_id -> action -> 调用
+	callers map[int64]*replayCaller
+	done    chan struct{}
+	once    sync.Once
+}
+
+type replayAPI struct {
+	params []byte
+	rec    *Record
+	used   bool
+}
+
+// NewReplayer 读取 path 中的录制, speed 参见 Replayer.Speed
+func NewReplayer(path string, speed float64) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := &Replayer{
+		Speed:   speed,
+		apis:    make(map[int64]map[string][]*replayAPI),
+		callers: make(map[int64]*replayCaller),
+		done:    make(chan struct{}),
+	}
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 64*1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		rec := new(Record)
+		if err := json.Unmarshal(s.Bytes(), rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if _, ok := r.callers[rec.SelfID]; !ok {
+			r.callers[rec.SelfID] = &replayCaller{r: r, selfID: rec.SelfID, version: rec.Version}
+		}
+		switch rec.Type {
+		case "event":
+			r.events = append(r.events, rec)
+		case "api":
+			if rec.Request == nil {
+				return nil, fmt.Errorf("%s:%d: api record without request", path, line)
+			}
+			actions, ok := r.apis[rec.SelfID]
+			if !ok {
+				actions = make(map[string][]*replayAPI)
+				r.apis[rec.SelfID] = actions
+			}
+			params, _ := json.Marshal(rec.Request.Params)
+			actions[rec.Request.Action] = append(actions[rec.Request.Action], &replayAPI{params: params, rec: rec})
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown record type %q", path, line, rec.Type)
+		}
+	}
+	return r, s.Err()
+}
+
+// Connect 将录制中出现的所有机器人添加到 APICallers
+func (r *Replayer) Connect() {
+	for id, c := range r.callers {
+		zero.APICallers.Store(id, c)
+	}
+	log.Infof("[replay] 载入 %d 个事件, %d 个机器人", len(r.events), len(r.callers))
+}
+
+// Listen 按录制的时间间隔与 Speed 依次交付事件, 全部交付或 Close 后返回
+func (r *Replayer) Listen(handler func([]byte, zero.APICaller)) {
+	defer r.once.Do(func() { close(r.done) })
+	var last time.Time
+	for i, rec := range r.events {
+		if i > 0 && r.Speed > 0 {
+			if d := time.Duration(float64(rec.Time.Sub(last)) / r.Speed); d > 0 {
+				t := time.NewTimer(d)
+				select {
+				case <-t.C:
+				case <-r.done:
+					t.Stop()
+					return
+				}
+			}
+		}
+		last = rec.Time
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+		handler(rec.Event, r.callers[rec.SelfID])
+	}
+	log.Infof("[replay] 回放结束")
+}
+
+// Done 所有事件交付或 Close 后关闭
+func (r *Replayer) Done() <-chan struct{} {
+	return r.done
+}
+
+// Close 停止回放并从 APICallers 中删除
+func (r *Replayer) Close() error {
+	r.once.Do(func() { close(r.done) })
+	for id, c := range r.callers {
+		if v, ok := zero.APICallers.Load(id); ok && v == zero.APICaller(c) {
+			zero.APICallers.Delete(id)
+		}
+	}
+	return nil
+}
+
+// find 查找 action 的录制, 优先返回参数相同且未使用的, 其次为最早未使用的, 均已使用时返回最后一次
+func (r *Replayer) find(selfID int64, req *zero.APIRequest) *Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := r.apis[selfID][req.Action]
+	if len(calls) == 0 {
+		return nil
+	}
+	params, _ := json.Marshal(req.Params)
+	var first *replayAPI
+	for _, c := range calls {
+		if c.used {
+			continue
+		}
+		if bytes.Equal(c.params, params) {
+			c.used = true
+			return c.rec
+		}
+		if first == nil {
+			first = c
+		}
+	}
+	if first != nil {
+		first.used = true
+		return first.rec
+	}
+	return calls[len(calls)-1].rec
+}
+
+// replayCaller 以录制的返回应答 API 调用
+type replayCaller struct {
+	r       *Replayer
+	selfID  int64
+	version int
+}
+
+// CallApi 返回录制的结果
+func (c *replayCaller) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
+	rec := c.r.find(c.selfID, &req)
+	if rec == nil {
+		log.Warnf("[replay] 未录制 %v 的调用", req.Action)
+		return nullResponse, fmt.Errorf("%w for %s", ErrNoRecord, req.Action)
+	}
+	if rec.Error != "" {
+		return nullResponse, errors.New(rec.Error)
+	}
+	if rec.Response == nil {
+		return nullResponse, fmt.Errorf("%w for %s", ErrNoRecord, req.Action)
+	}
+	return rec.Response.apiResponse(req.Echo), nil
+}
+
+// CallApiContext 返回录制的结果
+func (c *replayCaller) CallApiContext(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nullResponse, err
+	}
+	return c.CallApi(req)
+}
+
+// OneBotVersion 录制时的协议版本
+func (c *replayCaller) OneBotVersion() int {
+	if c.version == 0 {
+		return zero.OneBotV11
+	}
+	return c.version
+}
@@ -0,0 +1,149 @@
+package driver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// isV12Connect 判断是否为 OneBot 12 的连接元事件
+// https://12.onebot.dev/interface/meta/events/#metaconnect
+func isV12Connect(rsp gjson.Result) bool {
+	return rsp.Get("type").Str == "meta" && rsp.Get("detail_type").Str == "connect"
+}
+
+// v12Caller 为 OneBot 12 连接上的单个机器人附加 self 字段
+type v12Caller struct {
+	caller zero.APICaller
+	self   zero.Self
+	status gjson.Result // status_update 中 bots 的对应项
+}
+
+// CallApi 附加 self 后调用
+func (c *v12Caller) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
+	if req.Self == nil {
+		req.Self = &c.self
+	}
+	return c.caller.CallApi(req)
+}
+
+// CallApiContext 附加 self 后调用
+func (c *v12Caller) CallApiContext(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	if req.Self == nil {
+		req.Self = &c.self
+	}
+	return c.caller.CallApiContext(ctx, req)
+}
+
+// OneBotVersion 返回 12
+func (c *v12Caller) OneBotVersion() int {
+	return zero.OneBotV12
+}
+
+// v12Bots 记录一个 OneBot 12 连接上的所有机器人
+type v12Bots struct {
+	mu sync.Mutex
+	m  map[zero.Self]*v12Caller
+}
+
+// load 获取 self 对应的 caller, 首次出现时添加到 APICallers
+func (b *v12Bots) load(caller zero.APICaller, self zero.Self) *v12Caller {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.m == nil {
+		b.m = make(map[zero.Self]*v12Caller, 4)
+	}
+	c, ok := b.m[self]
+	if !ok {
+		c = &v12Caller{caller: caller, self: self}
+		b.m[self] = c
+		zero.APICallers.Store(zero.ParseV12ID(self.UserID), c) // 添加Caller到 APICaller list...
+		log.Infof("[onebot12] 账号上线: %s(%s)", self.UserID, self.Platform)
+	}
+	return c
+}
+
+// remove 从 APICallers 中删除 self
+func (b *v12Bots) remove(self zero.Self) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.m[self]; ok {
+		delete(b.m, self)
+		zero.APICallers.Delete(zero.ParseV12ID(self.UserID))
+		log.Infof("[onebot12] 账号离线: %s(%s)", self.UserID, self.Platform)
+	}
+}
+
+// clear 连接断开时删除所有机器人
+func (b *v12Bots) clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for self := range b.m {
+		zero.APICallers.Delete(zero.ParseV12ID(self.UserID))
+	}
+	b.m = nil
+}
+
+// heartbeat 收到连接的心跳, 为所有机器人上报状态
+func (b *v12Bots) heartbeat(interval time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for self, c := range b.m {
+		zero.ReportHeartbeat(zero.ParseV12ID(self.UserID), interval, c.status)
+	}
+}
+
+// update 根据 status_update 元事件或 get_status 响应更新机器人列表
+// https://12.onebot.dev/interface/meta/events/#metastatus_update
+func (b *v12Bots) update(caller zero.APICaller, status gjson.Result) {
+	status.Get("bots").ForEach(func(_, bot gjson.Result) bool {
+		self := zero.Self{
+			Platform: bot.Get("self.platform").Str,
+			UserID:   bot.Get("self.user_id").Str,
+		}
+		if bot.Get("online").Bool() {
+			c := b.load(caller, self)
+			b.mu.Lock()
+			c.status = bot
+			b.mu.Unlock()
+		} else {
+			b.remove(self)
+		}
+		return true
+	})
+}
+
+// handle 处理 OneBot 12 事件, 返回该事件对应的 caller
+//
+//	元事件返回 nil, 不再向上分发
+func (b *v12Bots) handle(caller zero.APICaller, rsp gjson.Result) zero.APICaller {
+	if rsp.Get("type").Str == "meta" {
+		switch rsp.Get("detail_type").Str {
+		case "status_update":
+			b.update(caller, rsp.Get("status"))
+		case "connect":
+			log.Infof("[onebot12] 已连接到 %s %s", rsp.Get("version.impl").Str, rsp.Get("version.version").Str)
+		}
+		return nil
+	}
+	return b.load(caller, zero.Self{
+		Platform: rsp.Get("self.platform").Str,
+		UserID:   rsp.Get("self.user_id").Str,
+	})
+}
+
+// fetch 通过 get_status 获取连接上的机器人列表
+// https://12.onebot.dev/interface/meta/actions/#get_status
+func (b *v12Bots) fetch(caller zero.APICaller) {
+	rsp, err := caller.CallApi(zero.APIRequest{Action: "get_status", Params: zero.Params{}})
+	if err != nil {
+		log.Warnln("[onebot12] 获取机器人列表失败:", err)
+		return
+	}
+	b.update(caller, rsp.Data)
+}
@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"context"
 	"encoding/base64"
 	"io"
 	"net"
@@ -21,6 +22,32 @@ import (
 
 var nullResponse = zero.APIResponse{}
 
+// apiTimeout ctx 未设置截止时间时 API 调用的最长等待时间
+const apiTimeout = time.Minute
+
+// waitResponse 等待 echo 对应的 API 返回, 超时或 ctx 取消时删除 seqMap 中的记录
+func waitResponse(ctx context.Context, seqMap *seqSyncMap, echo uint64, ch <-chan zero.APIResponse) (zero.APIResponse, error) {
+	var timeout <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok {
+		t := time.NewTimer(apiTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select { // 等待数据返回
+	case rsp, ok := <-ch:
+		if !ok {
+			return nullResponse, io.ErrClosedPipe
+		}
+		return rsp, nil
+	case <-ctx.Done():
+		seqMap.Delete(echo)
+		return nullResponse, ctx.Err()
+	case <-timeout:
+		seqMap.Delete(echo)
+		return nullResponse, os.ErrDeadlineExceeded
+	}
+}
+
 // WSClient ...
 type WSClient struct {
 	seq         uint64
@@ -30,6 +57,13 @@ type WSClient struct {
 	Url         string // ws连接地址
 	AccessToken string
 	selfID      int64
+	v12         bool    // 是否为 OneBot 12 连接
+	bots        v12Bots // OneBot 12 连接上的机器人
+	closed      uint32  // 已调用 Close
+	Backoff     Backoff // 断线重连的退避策略
+	done        chan struct{}
+	doneOnce    sync.Once
+	wd          watchdog // 心跳超时后断开重连
 }
 
 // NewWebSocketClient 默认Driver，使用正向WS通信
@@ -40,8 +74,27 @@ func NewWebSocketClient(url, accessToken string) *WSClient {
 	}
 }
 
-// Connect 连接ws服务端
+// dead 心跳超时, 断开连接以触发重连
+func (ws *WSClient) dead() {
+	log.Warnf("[ws] 连续 %d 个心跳间隔未收到Websocket服务器 %v 的心跳, 重新连接", HeartbeatMissLimit, ws.Url)
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	_ = ws.conn.Close()
+}
+
+// Connect 连接ws服务端, 失败时按 Backoff 重试
 func (ws *WSClient) Connect() {
+	ws.connect()
+}
+
+// closing Close 后关闭
+func (ws *WSClient) closing() chan struct{} {
+	ws.doneOnce.Do(func() { ws.done = make(chan struct{}) })
+	return ws.done
+}
+
+// connect 连接ws服务端, 返回是否成功
+func (ws *WSClient) connect() bool {
 	log.Infof("[ws] 开始尝试连接到Websocket服务器: %v", ws.Url)
 	header := http.Header{
 		"X-Client-Role": []string{"Universal"},
@@ -68,40 +121,93 @@ func (ws *WSClient) Connect() {
 		},
 	}
 
-	for {
+	for failed := 0; atomic.LoadUint32(&ws.closed) == 0; failed++ {
+		if failed > 0 {
+			if ws.Backoff.exhausted(failed) {
+				log.Errorf("[ws] 连接到Websocket服务器 %v 连续失败 %d 次, 放弃重连", ws.Url, failed)
+				return false
+			}
+			d := ws.Backoff.Duration(failed - 1)
+			log.Infof("[ws] 将在 %v 后第 %d 次重新连接", d.Round(time.Millisecond), failed)
+			t := time.NewTimer(d)
+			select {
+			case <-t.C:
+			case <-ws.closing():
+				t.Stop()
+				return false
+			}
+		}
 		conn, res, err := dialer.Dial(address, header)
 		if err != nil {
 			log.Warnf("[ws] 连接到Websocket服务器 %v 时出现错误: %v", ws.Url, err)
-			time.Sleep(2 * time.Second) // 等待两秒后重新连接
 			continue
 		}
-		ws.conn = conn
 		_ = res.Body.Close()
-		var rsp struct {
-			SelfID int64 `json:"self_id"`
-		}
-		err = ws.conn.ReadJSON(&rsp)
+		_, payload, err := conn.ReadMessage()
 		if err != nil {
+			_ = conn.Close()
 			log.Warnf("[ws] 与Websocket服务器 %v 握手时出现错误: %v", ws.Url, err)
-			time.Sleep(2 * time.Second) // 等待两秒后重新连接
 			continue
 		}
-		ws.selfID = rsp.SelfID
+		rsp := gjson.Parse(helper.BytesToString(payload))
+		ws.mu.Lock() // Close 在其他 goroutine 中读取 v12 与 selfID
+		if atomic.LoadUint32(&ws.closed) != 0 {
+			ws.mu.Unlock()
+			_ = conn.Close()
+			return false
+		}
+		ws.conn = conn
+		ws.v12 = isV12Connect(rsp)
+		if ws.v12 {
+			ws.selfID = 0
+			ws.mu.Unlock()
+			log.Infof("[ws] 连接Websocket服务器: %s 成功, 协议: OneBot 12", ws.Url)
+			return true
+		}
+		ws.selfID = rsp.Get("self_id").Int()
 		zero.APICallers.Store(ws.selfID, ws) // 添加Caller到 APICaller list...
-		log.Infof("[ws] 连接Websocket服务器: %s 成功, 账号: %d", ws.Url, rsp.SelfID)
-		break
+		ws.mu.Unlock()
+		log.Infof("[ws] 连接Websocket服务器: %s 成功, 账号: %d", ws.Url, ws.selfID)
+		return true
 	}
+	return false
 }
 
 // Listen 开始监听事件
+//
+//	连接成功、断开与重连时分别产生 sub_type 为 connect disconnect reconnect 的 lifecycle 元事件,
+//	OneBot 12 连接的元事件 self_id 为 0
 func (ws *WSClient) Listen(handler func([]byte, zero.APICaller)) {
+	if ws.conn == nil { // Connect 已放弃
+		return
+	}
+	if ws.v12 {
+		go ws.bots.fetch(ws)
+	}
+	ws.wd.dead = ws.dead
+	defer ws.wd.stop()
+	handler(lifecycleEvent(ws.selfID, LifecycleConnect), ws)
 	for {
 		t, payload, err := ws.conn.ReadMessage()
 		if err != nil { // reconnect
-			zero.APICallers.Delete(ws.selfID) // 断开从apicaller中删除
+			if atomic.LoadUint32(&ws.closed) != 0 {
+				log.Infoln("[ws] 已关闭与Websocket服务器的连接:", ws.Url)
+				return
+			}
+			ws.wd.stop()
+			if !ws.v12 {
+				zero.APICallers.Delete(ws.selfID) // 断开从apicaller中删除
+			}
+			ws.bots.clear()
 			log.Warn("[ws] Websocket服务器连接断开...")
-			time.Sleep(time.Millisecond * time.Duration(3))
-			ws.Connect()
+			handler(lifecycleEvent(ws.selfID, LifecycleDisconnect), ws)
+			if !ws.connect() {
+				return
+			}
+			if ws.v12 {
+				go ws.bots.fetch(ws)
+			}
+			handler(lifecycleEvent(ws.selfID, LifecycleReconnect), ws)
 			continue
 		}
 		if t != websocket.TextMessage {
@@ -123,7 +229,16 @@ func (ws *WSClient) Listen(handler func([]byte, zero.APICaller)) {
 			}
 			continue
 		}
-		if rsp.Get("meta_event_type").Str == "heartbeat" { // 忽略心跳事件
+		if handleHeartbeat(rsp, ws.selfID, &ws.bots, &ws.wd) { // 心跳不再向上分发
+			continue
+		}
+		if ws.v12 {
+			c := ws.bots.handle(ws, rsp)
+			if c == nil { // 忽略元事件
+				continue
+			}
+			log.Debug("[ws] 接收到事件: ", helper.BytesToString(payload))
+			handler(payload, c)
 			continue
 		}
 		log.Debug("[ws] 接收到事件: ", helper.BytesToString(payload))
@@ -131,12 +246,40 @@ func (ws *WSClient) Listen(handler func([]byte, zero.APICaller)) {
 	}
 }
 
+// Close 关闭连接, Listen 将返回且不再重连
+func (ws *WSClient) Close() error {
+	if !atomic.CompareAndSwapUint32(&ws.closed, 0, 1) {
+		return nil
+	}
+	close(ws.closing())
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if !ws.v12 {
+		zero.APICallers.Delete(ws.selfID)
+	}
+	ws.bots.clear()
+	if ws.conn == nil {
+		return nil
+	}
+	_ = ws.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	return ws.conn.Close()
+}
+
 func (ws *WSClient) nextSeq() uint64 {
 	return atomic.AddUint64(&ws.seq, 1)
 }
 
 // CallApi 发送ws请求
 func (ws *WSClient) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
+	return ws.CallApiContext(context.Background(), req)
+}
+
+// CallApiContext 发送ws请求, ctx 取消时放弃等待
+func (ws *WSClient) CallApiContext(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nullResponse, err
+	}
 	ch := make(chan zero.APIResponse, 1)
 	req.Echo = ws.nextSeq()
 	ws.seqMap.Store(req.Echo, ch)
@@ -146,18 +289,10 @@ func (ws *WSClient) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
 	err := ws.conn.WriteJSON(&req)
 	ws.mu.Unlock()
 	if err != nil {
+		ws.seqMap.Delete(req.Echo)
 		log.Warn("[ws] 向WebsocketServer发送API请求失败: ", err.Error())
 		return nullResponse, err
 	}
 	log.Debug("[ws] 向服务器发送请求: ", &req)
-
-	select { // 等待数据返回
-	case rsp, ok := <-ch:
-		if !ok {
-			return nullResponse, io.ErrClosedPipe
-		}
-		return rsp, nil
-	case <-time.After(time.Minute):
-		return nullResponse, os.ErrDeadlineExceeded
-	}
+	return waitResponse(ctx, &ws.seqMap, req.Echo, ch)
 }
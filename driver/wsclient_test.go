@@ -0,0 +1,186 @@
+package driver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RomiChan/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+func TestWaitResponseCancel(t *testing.T) {
+	var m seqSyncMap
+	ch := make(chan zero.APIResponse, 1)
+	m.Store(1, ch)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := waitResponse(ctx, &m, 1, ch)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, ok := m.Load(1)
+	assert.False(t, ok)
+}
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2, Jitter: -1}
+	assert.Equal(t, 100*time.Millisecond, b.Duration(0))
+	assert.Equal(t, 400*time.Millisecond, b.Duration(2))
+	assert.Equal(t, time.Second, b.Duration(10))
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := b.Duration(1)
+		assert.True(t, d >= 100*time.Millisecond && d <= 300*time.Millisecond, d)
+	}
+	assert.False(t, b.exhausted(100))
+	b.MaxAttempts = 3
+	assert.True(t, b.exhausted(3))
+}
+
+func TestWSClientLifecycle(t *testing.T) {
+	var conns int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = conn.WriteMessage(websocket.TextMessage, lifecycleEvent(10001, "connect"))
+		if atomic.AddInt32(&conns, 1) == 1 { // 第一次连接后立即断开
+			_ = conn.Close()
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	ws := NewWebSocketClient("ws"+strings.TrimPrefix(srv.URL, "http"), "")
+	ws.Backoff = Backoff{Initial: 10 * time.Millisecond, Jitter: -1}
+	ws.Connect()
+	events := make(chan string, 8)
+	go ws.Listen(func(data []byte, _ zero.APICaller) {
+		events <- gjson.GetBytes(data, "sub_type").String()
+	})
+	for _, want := range []string{LifecycleConnect, LifecycleDisconnect, LifecycleReconnect} {
+		select {
+		case got := <-events:
+			assert.Equal(t, want, got)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for", want)
+		}
+	}
+	assert.NoError(t, ws.Close())
+
+	ws = NewWebSocketClient("ws://127.0.0.1:1", "")
+	ws.Backoff = Backoff{Initial: time.Millisecond, MaxAttempts: 2}
+	ws.Connect()
+	ws.Listen(func([]byte, zero.APICaller) { t.Fatal("unexpected event") })
+}
+
+func TestWSClientHeartbeat(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.WriteMessage(websocket.TextMessage, lifecycleEvent(10002, "connect"))
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"time":0,"self_id":10002,"post_type":"meta_event",`+
+			`"meta_event_type":"heartbeat","interval":20,"status":{"online":true,"good":false}}`))
+		for { // 之后不再发送心跳
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	ws := NewWebSocketClient("ws"+strings.TrimPrefix(srv.URL, "http"), "")
+	ws.Backoff = Backoff{Initial: 10 * time.Millisecond, Jitter: -1}
+	ws.Connect()
+	events := make(chan string, 8)
+	go ws.Listen(func(data []byte, _ zero.APICaller) {
+		events <- gjson.GetBytes(data, "sub_type").String()
+	})
+	for _, want := range []string{LifecycleConnect, LifecycleDisconnect, LifecycleReconnect} {
+		select {
+		case got := <-events:
+			assert.Equal(t, want, got)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for", want)
+		}
+	}
+	assert.NoError(t, ws.Close())
+
+	s, ok := zero.GetBotStatus(10002)
+	assert.True(t, ok)
+	assert.Equal(t, 20*time.Millisecond, s.Interval)
+	assert.False(t, s.Online) // 已关闭连接
+	assert.False(t, s.Good)
+}
+
+func TestWSClientCloseV12(t *testing.T) {
+	var conns int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		n := atomic.AddInt32(&conns, 1)
+		if n == 1 { // 先以 OneBot 11 连接后断开
+			_ = conn.WriteMessage(websocket.TextMessage, lifecycleEvent(10003, "connect"))
+			return
+		}
+		_ = conn.WriteMessage(websocket.TextMessage,
+			[]byte(`{"type":"meta","detail_type":"connect","version":{"impl":"test","version":"1"}}`))
+		if n > 2 { // 之后反复断开, 与 Close 并发重连
+			return
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	ws := NewWebSocketClient("ws"+strings.TrimPrefix(srv.URL, "http"), "")
+	ws.Backoff = Backoff{Initial: time.Millisecond, Jitter: -1}
+	ws.Connect()
+	events := make(chan string, 8)
+	go ws.Listen(func(data []byte, _ zero.APICaller) {
+		events <- gjson.GetBytes(data, "sub_type").String()
+	})
+	for _, want := range []string{LifecycleConnect, LifecycleDisconnect, LifecycleReconnect} {
+		select {
+		case got := <-events:
+			assert.Equal(t, want, got)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for", want)
+		}
+	}
+	other := NewWebSocketClient("", "") // 其他连接登录了同一账号
+	zero.APICallers.Store(10003, other)
+	defer zero.APICallers.Delete(10003)
+	assert.NoError(t, ws.Close())
+	c, ok := zero.APICallers.Load(10003) // Close OneBot 12 连接不删除 OneBot 11 时的账号
+	assert.True(t, ok)
+	assert.Equal(t, zero.APICaller(other), c)
+
+	ws = NewWebSocketClient("ws"+strings.TrimPrefix(srv.URL, "http"), "")
+	ws.Backoff = Backoff{Initial: time.Millisecond, Jitter: -1}
+	ws.Connect()
+	go ws.Listen(func([]byte, zero.APICaller) {})
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, ws.Close())
+}
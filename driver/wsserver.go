@@ -1,17 +1,21 @@
 package driver
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unsafe"
 
 	"github.com/RomiChan/websocket"
 	log "github.com/sirupsen/logrus"
@@ -21,27 +25,42 @@ import (
 	"github.com/wdvxdr1123/ZeroBot/utils/helper"
 )
 
-// WSServer ...
+// WSServer 反向 WS, 支持 Universal 与 API/Event 分离的连接
+//
+//	Url 为 wss:// 时使用 TLS, 需设置 CertFile 与 KeyFile, 设置 ClientCAFile 时要求并校验客户端证书
 type WSServer struct {
-	Url         string // ws连接地址
-	AccessToken string
-	lstn        net.Listener
-	caller      chan *WSSCaller
+	Url          string // ws连接地址
+	AccessToken  string
+	CertFile     string // TLS 证书
+	KeyFile      string // TLS 私钥
+	ClientCAFile string // 校验客户端证书的 CA, 为空时不校验
+	lstn         net.Listener
+	caller       chan *WSSCaller
+	closed       uint32 // 已调用 Close
+	done         chan struct{}
+	mu           sync.Mutex
+	conns        map[*WSSCaller]struct{} // 已连接的客户端
 
 	json.Unmarshaler
 }
 
 // UnmarshalJSON init WSServer with waitn=16
 func (wss *WSServer) UnmarshalJSON(data []byte) error {
-	type jsoncfg struct {
-		Url         string // ws连接地址
-		AccessToken string
+	var cfg struct {
+		Url          string // ws连接地址
+		AccessToken  string
+		CertFile     string
+		KeyFile      string
+		ClientCAFile string
 	}
-	err := json.Unmarshal(data, (*jsoncfg)(unsafe.Pointer(wss)))
+	err := json.Unmarshal(data, &cfg)
 	if err != nil {
 		return err
 	}
+	wss.Url, wss.AccessToken = cfg.Url, cfg.AccessToken
+	wss.CertFile, wss.KeyFile, wss.ClientCAFile = cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile
 	wss.caller = make(chan *WSSCaller, 16)
+	wss.done = make(chan struct{})
 	return nil
 }
 
@@ -51,16 +70,48 @@ func NewWebSocketServer(waitn int, url, accessToken string) *WSServer {
 		Url:         url,
 		AccessToken: accessToken,
 		caller:      make(chan *WSSCaller, waitn),
+		done:        make(chan struct{}),
 	}
 }
 
+// 反向 WS 连接的角色
+// https://github.com/botuniverse/onebot-11/blob/master/communication/ws-reverse.md
+const (
+	roleUniversal = "Universal"
+	roleAPI       = "API"   // 仅用于 API 调用
+	roleEvent     = "Event" // 仅用于事件上报
+)
+
+// clientRole 获取连接的角色, 优先使用 X-Client-Role, 其次根据路径 /api /event 判断
+func clientRole(r *http.Request) string {
+	switch strings.ToLower(r.Header.Get("X-Client-Role")) {
+	case "api":
+		return roleAPI
+	case "event":
+		return roleEvent
+	case "universal":
+		return roleUniversal
+	}
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/api"):
+		return roleAPI
+	case strings.HasSuffix(r.URL.Path, "/event"):
+		return roleEvent
+	}
+	return roleUniversal
+}
+
 // WSSCaller ...
 type WSSCaller struct {
 	mu     sync.Mutex // 写锁
 	seqMap seqSyncMap
 	conn   *websocket.Conn
+	role   string
 	selfID int64
 	seq    uint64
+	v12    bool     // 是否为 OneBot 12 连接
+	bots   v12Bots  // OneBot 12 连接上的机器人
+	wd     watchdog // 心跳超时后断开连接
 }
 
 var upgrader = websocket.Upgrader{
@@ -73,6 +124,7 @@ var upgrader = websocket.Upgrader{
 func (wss *WSServer) Connect() {
 	network, address := resolveURI(wss.Url)
 	uri, err := url.Parse(address)
+	secure := err == nil && uri.Scheme == "wss"
 	if err == nil && uri.Scheme != "" {
 		address = uri.Host
 	}
@@ -83,11 +135,46 @@ func (wss *WSServer) Connect() {
 		wss.lstn = nil
 		return
 	}
+	if secure {
+		cfg, err := wss.tlsConfig()
+		if err != nil {
+			_ = listener.Close()
+			log.Warn("[wss] 加载TLS证书失败:", err)
+			wss.lstn = nil
+			return
+		}
+		listener = tls.NewListener(listener, cfg)
+	}
 
 	wss.lstn = listener
 	log.Infoln("[wss] Websocket服务器开始监听:", listener.Addr())
 }
 
+// tlsConfig 加载证书, 设置 ClientCAFile 时要求并校验客户端证书
+func (wss *WSServer) tlsConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(wss.CertFile, wss.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if wss.ClientCAFile != "" {
+		pem, err := os.ReadFile(wss.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in " + wss.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
 func checkAuth(req *http.Request, token string) int {
 	if token == "" { // quick path
 		return http.StatusOK
@@ -121,59 +208,198 @@ func (wss *WSServer) any(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Warnf("[wss] 处理 WebSocket 请求时出现错误: %v", err)
-		return
+	var header http.Header
+	if proto := r.Header.Get("Sec-WebSocket-Protocol"); strings.HasPrefix(proto, "12.") { // OneBot 12
+		header = http.Header{"Sec-WebSocket-Protocol": []string{proto}}
 	}
-
-	var rsp struct {
-		SelfID int64 `json:"self_id"`
+	role := clientRole(r)
+	selfID, _ := strconv.ParseInt(r.Header.Get("X-Self-ID"), 10, 64)
+	if role == roleAPI && selfID == 0 {
+		log.Warnf("[wss] 已拒绝 %v 的 API 连接: 缺少 X-Self-ID", r.RemoteAddr)
+		w.WriteHeader(http.StatusBadRequest)
+		return
 	}
-	err = conn.ReadJSON(&rsp)
+	conn, err := upgrader.Upgrade(w, r, header)
 	if err != nil {
-		log.Warnf("[wss] 与Websocket服务器 %v 握手时出现错误: %v", wss.Url, err)
+		log.Warnf("[wss] 处理 WebSocket 请求时出现错误: %v", err)
 		return
 	}
 
 	c := &WSSCaller{
 		conn:   conn,
-		selfID: rsp.SelfID,
+		role:   role,
+		selfID: selfID,
+	}
+	if role != roleAPI { // API 连接不上报事件, 无需等待 lifecycle 元事件
+		_, payload, err := conn.ReadMessage()
+		if err != nil {
+			log.Warnf("[wss] 与Websocket服务器 %v 握手时出现错误: %v", wss.Url, err)
+			return
+		}
+		rsp := gjson.Parse(helper.BytesToString(payload))
+		if isV12Connect(rsp) {
+			log.Infof("[wss] 连接Websocket服务器: %s 成功, 协议: OneBot 12", wss.Url)
+			c.v12 = true
+			wss.caller <- c
+			return
+		}
+		if id := rsp.Get("self_id").Int(); id != 0 {
+			c.selfID = id
+		}
 	}
-	zero.APICallers.Store(rsp.SelfID, c) // 添加Caller到 APICaller list...
-	log.Infof("[wss] 连接Websocket服务器: %s 成功, 账号: %d", wss.Url, rsp.SelfID)
+	if role != roleEvent {
+		zero.APICallers.Store(c.selfID, c) // 添加Caller到 APICaller list...
+	}
+	log.Infof("[wss] 连接Websocket服务器: %s 成功, 账号: %d, 角色: %s", wss.Url, c.selfID, role)
 	wss.caller <- c
 }
 
+// ErrNoAPIConn Event 连接上的账号没有 API 连接
+var ErrNoAPIConn = errors.New("no API connection")
+
+// noAPICaller Event 连接上的账号没有 API 连接时使用, 调用立即返回 ErrNoAPIConn
+type noAPICaller int64
+
+// CallApi 返回 ErrNoAPIConn
+func (c noAPICaller) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
+	return nullResponse, fmt.Errorf("%w for self_id %d", ErrNoAPIConn, int64(c))
+}
+
+// CallApiContext 返回 ErrNoAPIConn
+func (c noAPICaller) CallApiContext(_ context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	return c.CallApi(req)
+}
+
+// eventCaller Event 连接上的事件使用同一账号 API 连接的 caller
+//
+//	Event 连接不应答 API 请求, 没有 API 连接时 API 调用立即失败
+func (wssc *WSSCaller) eventCaller() zero.APICaller {
+	if wssc.role == roleEvent {
+		if c, ok := zero.APICallers.Load(wssc.selfID); ok {
+			return c
+		}
+		return noAPICaller(wssc.selfID)
+	}
+	return wssc
+}
+
+// v12EventCaller OneBot 12 Event 连接上的事件使用已注册的同一账号的 caller, 不添加到 APICallers
+//
+//	元事件返回 nil
+func (wssc *WSSCaller) v12EventCaller(rsp gjson.Result) zero.APICaller {
+	if rsp.Get("type").Str == "meta" {
+		return nil
+	}
+	self := zero.Self{
+		Platform: rsp.Get("self.platform").Str,
+		UserID:   rsp.Get("self.user_id").Str,
+	}
+	id := zero.ParseV12ID(self.UserID)
+	if c, ok := zero.APICallers.Load(id); ok {
+		return c
+	}
+	return &v12Caller{caller: noAPICaller(id), self: self}
+}
+
 // Listen 开始监听事件
 func (wss *WSServer) Listen(handler func([]byte, zero.APICaller)) {
 	mux := http.ServeMux{}
 	mux.HandleFunc("/", wss.any)
 	go func() {
-		for {
+		for failed := 0; atomic.LoadUint32(&wss.closed) == 0; {
 			if wss.lstn == nil {
-				time.Sleep(time.Millisecond * time.Duration(3))
+				time.Sleep(DefaultBackoff.Duration(failed))
+				failed++
 				wss.Connect()
 				continue
 			}
+			failed = 0
 			log.Infof("[wss] WebSocket 服务器开始处理: %v", wss.lstn.Addr())
 			err := http.Serve(wss.lstn, &mux)
+			if atomic.LoadUint32(&wss.closed) != 0 {
+				return
+			}
 			if err != nil {
 				log.Warn("[wss] Websocket服务器在端点", wss.lstn.Addr(), "失败:", err)
 				wss.lstn = nil
 			}
 		}
 	}()
-	for wssc := range wss.caller {
-		go wssc.listen(handler)
+	for {
+		select {
+		case wssc := <-wss.caller:
+			go func() {
+				if !wss.track(wssc) {
+					_ = wssc.conn.Close()
+					return
+				}
+				wssc.listen(handler)
+				wss.untrack(wssc)
+			}()
+		case <-wss.done:
+			log.Infoln("[wss] 已关闭Websocket服务器:", wss.Url)
+			return
+		}
+	}
+}
+
+// track 记录已连接的客户端, 已关闭时返回 false
+func (wss *WSServer) track(wssc *WSSCaller) bool {
+	wss.mu.Lock()
+	defer wss.mu.Unlock()
+	if atomic.LoadUint32(&wss.closed) != 0 {
+		return false
+	}
+	if wss.conns == nil {
+		wss.conns = make(map[*WSSCaller]struct{}, 4)
+	}
+	wss.conns[wssc] = struct{}{}
+	return true
+}
+
+func (wss *WSServer) untrack(wssc *WSSCaller) {
+	wss.mu.Lock()
+	delete(wss.conns, wssc)
+	wss.mu.Unlock()
+}
+
+// Close 停止监听并断开所有客户端, Listen 将返回
+func (wss *WSServer) Close() error {
+	if !atomic.CompareAndSwapUint32(&wss.closed, 0, 1) {
+		return nil
 	}
+	if wss.done != nil {
+		close(wss.done)
+	}
+	var err error
+	if wss.lstn != nil {
+		err = wss.lstn.Close()
+	}
+	wss.mu.Lock()
+	defer wss.mu.Unlock()
+	for wssc := range wss.conns {
+		wssc.close()
+	}
+	return err
 }
 
 func (wssc *WSSCaller) listen(handler func([]byte, zero.APICaller)) {
+	if wssc.v12 && wssc.role != roleEvent { // Event 连接无法调用 API
+		go wssc.bots.fetch(wssc)
+	}
+	wssc.wd.dead = func() {
+		log.Warnf("[wss] 连续 %d 个心跳间隔未收到客户端 %v 的心跳, 断开连接", HeartbeatMissLimit, wssc.conn.RemoteAddr())
+		wssc.close()
+	}
+	defer wssc.wd.stop()
 	for {
 		t, payload, err := wssc.conn.ReadMessage()
 		if err != nil { // reconnect
-			zero.APICallers.Delete(wssc.selfID) // 断开从apicaller中删除
+			if wssc.v12 {
+				wssc.bots.clear()
+			} else if c, ok := zero.APICallers.Load(wssc.selfID); ok && c == zero.APICaller(wssc) {
+				zero.APICallers.Delete(wssc.selfID) // 断开从apicaller中删除
+			}
 			log.Warn("[wss] Websocket服务器连接断开...")
 			return
 		}
@@ -196,20 +422,51 @@ func (wssc *WSSCaller) listen(handler func([]byte, zero.APICaller)) {
 			}
 			continue
 		}
-		if rsp.Get("meta_event_type").Str == "heartbeat" { // 忽略心跳事件
+		if handleHeartbeat(rsp, wssc.selfID, &wssc.bots, &wssc.wd) { // 心跳不再向上分发
+			continue
+		}
+		if wssc.v12 {
+			var c zero.APICaller
+			if wssc.role == roleEvent {
+				c = wssc.v12EventCaller(rsp)
+			} else {
+				c = wssc.bots.handle(wssc, rsp)
+			}
+			if c == nil { // 忽略元事件
+				continue
+			}
+			log.Debug("[wss] 接收到事件: ", helper.BytesToString(payload))
+			handler(payload, c)
 			continue
 		}
 		log.Debug("[wss] 接收到事件: ", helper.BytesToString(payload))
-		handler(payload, wssc)
+		handler(payload, wssc.eventCaller())
 	}
 }
 
+// close 向客户端发送关闭帧并断开连接, listen 将返回
+func (wssc *WSSCaller) close() {
+	wssc.mu.Lock()
+	defer wssc.mu.Unlock()
+	_ = wssc.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	_ = wssc.conn.Close()
+}
+
 func (wssc *WSSCaller) nextSeq() uint64 {
 	return atomic.AddUint64(&wssc.seq, 1)
 }
 
 // CallApi 发送ws请求
 func (wssc *WSSCaller) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
+	return wssc.CallApiContext(context.Background(), req)
+}
+
+// CallApiContext 发送ws请求, ctx 取消时放弃等待
+func (wssc *WSSCaller) CallApiContext(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nullResponse, err
+	}
 	ch := make(chan zero.APIResponse, 1)
 	req.Echo = wssc.nextSeq()
 	wssc.seqMap.Store(req.Echo, ch)
@@ -219,18 +476,10 @@ func (wssc *WSSCaller) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
 	err := wssc.conn.WriteJSON(&req)
 	wssc.mu.Unlock()
 	if err != nil {
+		wssc.seqMap.Delete(req.Echo)
 		log.Warn("[wss] 向WebsocketServer发送API请求失败: ", err.Error())
 		return nullResponse, err
 	}
 	log.Debug("[wss] 向服务器发送请求: ", &req)
-
-	select { // 等待数据返回
-	case rsp, ok := <-ch:
-		if !ok {
-			return nullResponse, io.ErrClosedPipe
-		}
-		return rsp, nil
-	case <-time.After(time.Minute):
-		return nullResponse, os.ErrDeadlineExceeded
-	}
+	return waitResponse(ctx, &wssc.seqMap, req.Echo, ch)
 }
@@ -0,0 +1,196 @@
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RomiChan/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+func TestClientRole(t *testing.T) {
+	newReq := func(path, role string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if role != "" {
+			r.Header.Set("X-Client-Role", role)
+		}
+		return r
+	}
+	assert.Equal(t, roleUniversal, clientRole(newReq("/", "")))
+	assert.Equal(t, roleAPI, clientRole(newReq("/onebot/api", "")))
+	assert.Equal(t, roleEvent, clientRole(newReq("/event", "")))
+	assert.Equal(t, roleEvent, clientRole(newReq("/", "Event")))
+	assert.Equal(t, roleUniversal, clientRole(newReq("/api", "Universal")))
+}
+
+// startWSServer 启动监听随机端口的 WSServer, 返回其地址与收到的事件
+func startWSServer(t *testing.T, wss *WSServer) (string, chan zero.APICaller) {
+	wss.Connect()
+	if !assert.NotNil(t, wss.lstn) {
+		t.FailNow()
+	}
+	events := make(chan zero.APICaller, 4)
+	go wss.Listen(func(data []byte, caller zero.APICaller) {
+		events <- caller
+	})
+	t.Cleanup(func() { _ = wss.Close() })
+	return wss.lstn.Addr().String(), events
+}
+
+func TestWSServerSplitRoles(t *testing.T) {
+	addr, events := startWSServer(t, NewWebSocketServer(4, "ws://127.0.0.1:0", ""))
+	header := http.Header{"X-Self-ID": []string{"20001"}}
+	api, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/api", header)
+	assert.NoError(t, err)
+	defer api.Close()
+	event, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/event", header)
+	assert.NoError(t, err)
+	defer event.Close()
+	assert.NoError(t, event.WriteMessage(websocket.TextMessage, lifecycleEvent(20001, "connect")))
+
+	assert.Eventually(t, func() bool {
+		_, ok := zero.APICallers.Load(20001)
+		return ok
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, event.WriteMessage(websocket.TextMessage,
+		[]byte(`{"self_id":20001,"post_type":"message","message_type":"private","user_id":1,"message":"hi"}`)))
+	var caller zero.APICaller
+	select {
+	case caller = <-events:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	assert.Equal(t, roleAPI, caller.(*WSSCaller).role)
+
+	go func() { // API 连接应答
+		_, payload, err := api.ReadMessage()
+		if err != nil {
+			return
+		}
+		echo := gjson.GetBytes(payload, "echo").Uint()
+		_ = api.WriteJSON(zero.H{"status": "ok", "retcode": 0, "data": zero.H{"user_id": 20001}, "echo": echo})
+	}()
+	rsp, err := caller.CallApi(zero.APIRequest{Action: "get_login_info"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20001), rsp.Data.Get("user_id").Int())
+}
+
+func TestWSServerV12EventRole(t *testing.T) {
+	addr, events := startWSServer(t, NewWebSocketServer(4, "ws://127.0.0.1:0", ""))
+	event, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/event", nil)
+	assert.NoError(t, err)
+	defer event.Close()
+	assert.NoError(t, event.WriteMessage(websocket.TextMessage,
+		[]byte(`{"type":"meta","detail_type":"connect","version":{"impl":"test","version":"1"}}`)))
+	assert.NoError(t, event.WriteMessage(websocket.TextMessage,
+		[]byte(`{"type":"message","detail_type":"private","self":{"platform":"qq","user_id":"20003"},"user_id":"1","message":[]}`)))
+	select {
+	case caller := <-events:
+		_, ok := caller.(*v12Caller)
+		assert.True(t, ok)
+		_, err := caller.CallApi(zero.APIRequest{Action: "get_status"})
+		assert.ErrorIs(t, err, ErrNoAPIConn)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	_, ok := zero.APICallers.Load(20003) // Event 连接不添加到 APICallers
+	assert.False(t, ok)
+}
+
+func TestWSServerEventOnly(t *testing.T) {
+	addr, events := startWSServer(t, NewWebSocketServer(4, "ws://127.0.0.1:0", ""))
+	event, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/event", http.Header{"X-Self-ID": []string{"20004"}})
+	assert.NoError(t, err)
+	defer event.Close()
+	assert.NoError(t, event.WriteMessage(websocket.TextMessage, lifecycleEvent(20004, "connect")))
+	assert.NoError(t, event.WriteMessage(websocket.TextMessage,
+		[]byte(`{"self_id":20004,"post_type":"message","message_type":"private","user_id":1,"message":"hi"}`)))
+	var caller zero.APICaller
+	select {
+	case caller = <-events:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	start := time.Now()
+	_, err = caller.CallApi(zero.APIRequest{Action: "get_login_info"}) // 没有 API 连接, 立即失败
+	assert.EqualError(t, err, "no API connection for self_id 20004")
+	assert.ErrorIs(t, err, ErrNoAPIConn)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestWSServerUnmarshalJSON(t *testing.T) {
+	var wss WSServer
+	assert.NoError(t, json.Unmarshal([]byte(`{"Url":"wss://127.0.0.1:0","AccessToken":"t","CertFile":"c","KeyFile":"k","ClientCAFile":"ca"}`), &wss))
+	assert.Equal(t, "wss://127.0.0.1:0", wss.Url)
+	assert.Equal(t, "t", wss.AccessToken)
+	assert.Equal(t, "ca", wss.ClientCAFile)
+	assert.NotNil(t, wss.caller)
+}
+
+func TestWSServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	pool := writeTestCert(t, certFile, keyFile)
+
+	wss := NewWebSocketServer(4, "wss://127.0.0.1:0", "")
+	wss.CertFile, wss.KeyFile = certFile, keyFile
+	addr, _ := startWSServer(t, wss)
+
+	dialer := websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}}
+	conn, _, err := dialer.Dial("wss://"+addr+"/", http.Header{"X-Self-ID": []string{"20002"}})
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, lifecycleEvent(20002, "connect")))
+	assert.Eventually(t, func() bool {
+		_, ok := zero.APICallers.Load(20002)
+		return ok
+	}, time.Second, 10*time.Millisecond)
+
+	_, _, err = websocket.DefaultDialer.Dial("ws://"+addr+"/", nil) // 非 TLS 连接被拒绝
+	assert.Error(t, err)
+}
+
+// writeTestCert 生成 127.0.0.1 的自签名证书
+func writeTestCert(t *testing.T, certFile, keyFile string) *x509.CertPool {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "zerobot test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return pool
+}
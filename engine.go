@@ -18,6 +18,7 @@ type Engine struct {
 	postHandler []Handler
 	block       bool
 	matchers    []IMatcher
+	name        string
 }
 
 func (e *Engine) getPreHandler() []Rule {
@@ -38,9 +39,9 @@ func (e *Engine) getBlock() bool {
 
 func init() {
 	defaultEngine.UsePreHandler(
-		func(ctx Context) bool {
+		SyncRule(func(ctx Context) bool {
 			return ctx.GetEvent().UserID != ctx.GetEvent().SelfID || ctx.GetEvent().PostType != "message"
-		},
+		}),
 	)
 }
 
@@ -56,6 +57,17 @@ func (e *Engine) SetBlock(block bool) IEngine {
 	return e
 }
 
+// SetName 设置 Engine 的名称, 用于 ListMatchers
+func (e *Engine) SetName(name string) IEngine {
+	e.name = name
+	return e
+}
+
+// GetName 获取 Engine 的名称
+func (e *Engine) GetName() string {
+	return e.name
+}
+
 // UsePreHandler 向该 Engine 添加新 PreHandler(Rule),
 // 会在 Rule 判断前触发，如果 preHandler
 // 没有通过，则 Rule, Matcher 不会触发
@@ -89,9 +101,10 @@ func On(typ string, rules ...Rule) IMatcher { return defaultEngine.On(typ, rules
 // On 添加新的指定消息类型的匹配器
 func (e *Engine) On(typ string, rules ...Rule) IMatcher {
 	matcher := &Matcher{
-		Type:   Type(typ),
-		Rules:  rules,
-		Engine: e,
+		Type:    Type(typ),
+		Rules:   rules,
+		Engine:  e,
+		trigger: trigger{kind: TriggerOn, typ: typ},
 	}
 	e.matchers = append(e.matchers, matcher)
 	return StoreMatcher(matcher)
@@ -127,9 +140,10 @@ func OnPrefix(prefix string, rules ...Rule) IMatcher { return defaultEngine.OnPr
 // OnPrefix 前缀触发器
 func (e *Engine) OnPrefix(prefix string, rules ...Rule) IMatcher {
 	matcher := &Matcher{
-		Type:   Type("message"),
-		Rules:  append([]Rule{PrefixRule(prefix)}, rules...),
-		Engine: e,
+		Type:    Type("message"),
+		Rules:   append([]Rule{PrefixRule(prefix)}, rules...),
+		Engine:  e,
+		trigger: trigger{kind: TriggerPrefix, typ: "message", literals: []string{prefix}},
 	}
 	e.matchers = append(e.matchers, matcher)
 	return StoreMatcher(matcher)
@@ -141,9 +155,10 @@ func OnSuffix(suffix string, rules ...Rule) IMatcher { return defaultEngine.OnSu
 // OnSuffix 后缀触发器
 func (e *Engine) OnSuffix(suffix string, rules ...Rule) IMatcher {
 	matcher := &Matcher{
-		Type:   Type("message"),
-		Rules:  append([]Rule{SuffixRule(suffix)}, rules...),
-		Engine: e,
+		Type:    Type("message"),
+		Rules:   append([]Rule{SuffixRule(suffix)}, rules...),
+		Engine:  e,
+		trigger: trigger{kind: TriggerSuffix, typ: "message", literals: []string{suffix}},
 	}
 	e.matchers = append(e.matchers, matcher)
 	return StoreMatcher(matcher)
@@ -157,9 +172,10 @@ func OnCommand(commands string, rules ...Rule) IMatcher {
 // OnCommand 命令触发器
 func (e *Engine) OnCommand(commands string, rules ...Rule) IMatcher {
 	matcher := &Matcher{
-		Type:   Type("message"),
-		Rules:  append([]Rule{CommandRule(commands)}, rules...),
-		Engine: e,
+		Type:    Type("message"),
+		Rules:   append([]Rule{CommandRule(commands)}, rules...),
+		Engine:  e,
+		trigger: trigger{kind: TriggerCommand, typ: "message", literals: []string{commands}},
 	}
 	e.matchers = append(e.matchers, matcher)
 	return StoreMatcher(matcher)
@@ -167,15 +183,16 @@ func (e *Engine) OnCommand(commands string, rules ...Rule) IMatcher {
 
 // OnRegex 正则触发器
 func OnRegex(regexPattern string, rules ...Rule) IMatcher {
-	return OnMessage(append([]Rule{RegexRule(regexPattern)}, rules...)...)
+	return defaultEngine.OnRegex(regexPattern, rules...)
 }
 
 // OnRegex 正则触发器
 func (e *Engine) OnRegex(regexPattern string, rules ...Rule) IMatcher {
 	matcher := &Matcher{
-		Type:   Type("message"),
-		Rules:  append([]Rule{RegexRule(regexPattern)}, rules...),
-		Engine: e,
+		Type:    Type("message"),
+		Rules:   append([]Rule{RegexRule(regexPattern)}, rules...),
+		Engine:  e,
+		trigger: trigger{kind: TriggerRegex, typ: "message", regex: regexPattern},
 	}
 	e.matchers = append(e.matchers, matcher)
 	return StoreMatcher(matcher)
@@ -189,9 +206,10 @@ func OnKeyword(keyword string, rules ...Rule) IMatcher {
 // OnKeyword 关键词触发器
 func (e *Engine) OnKeyword(keyword string, rules ...Rule) IMatcher {
 	matcher := &Matcher{
-		Type:   Type("message"),
-		Rules:  append([]Rule{KeywordRule(keyword)}, rules...),
-		Engine: e,
+		Type:    Type("message"),
+		Rules:   append([]Rule{KeywordRule(keyword)}, rules...),
+		Engine:  e,
+		trigger: trigger{kind: TriggerKeyword, typ: "message", literals: []string{keyword}},
 	}
 	e.matchers = append(e.matchers, matcher)
 	return StoreMatcher(matcher)
@@ -205,9 +223,10 @@ func OnFullMatch(src string, rules ...Rule) IMatcher {
 // OnFullMatch 完全匹配触发器
 func (e *Engine) OnFullMatch(src string, rules ...Rule) IMatcher {
 	matcher := &Matcher{
-		Type:   Type("message"),
-		Rules:  append([]Rule{FullMatchRule(src)}, rules...),
-		Engine: e,
+		Type:    Type("message"),
+		Rules:   append([]Rule{FullMatchRule(src)}, rules...),
+		Engine:  e,
+		trigger: trigger{kind: TriggerFullMatch, typ: "message", literals: []string{src}},
 	}
 	e.matchers = append(e.matchers, matcher)
 	return StoreMatcher(matcher)
@@ -221,9 +240,10 @@ func OnFullMatchGroup(src []string, rules ...Rule) IMatcher {
 // OnFullMatchGroup 完全匹配触发器组
 func (e *Engine) OnFullMatchGroup(src []string, rules ...Rule) IMatcher {
 	matcher := &Matcher{
-		Type:   Type("message"),
-		Rules:  append([]Rule{FullMatchRule(src...)}, rules...),
-		Engine: e,
+		Type:    Type("message"),
+		Rules:   append([]Rule{FullMatchRule(src...)}, rules...),
+		Engine:  e,
+		trigger: trigger{kind: TriggerFullMatch, typ: "message", literals: src},
 	}
 	e.matchers = append(e.matchers, matcher)
 	return StoreMatcher(matcher)
@@ -237,9 +257,10 @@ func OnKeywordGroup(keywords []string, rules ...Rule) IMatcher {
 // OnKeywordGroup 关键词触发器组
 func (e *Engine) OnKeywordGroup(keywords []string, rules ...Rule) IMatcher {
 	matcher := &Matcher{
-		Type:   Type("message"),
-		Rules:  append([]Rule{KeywordRule(keywords...)}, rules...),
-		Engine: e,
+		Type:    Type("message"),
+		Rules:   append([]Rule{KeywordRule(keywords...)}, rules...),
+		Engine:  e,
+		trigger: trigger{kind: TriggerKeyword, typ: "message", literals: keywords},
 	}
 	e.matchers = append(e.matchers, matcher)
 	return StoreMatcher(matcher)
@@ -252,7 +273,14 @@ func OnCommandGroup(commands []string, rules ...Rule) IMatcher {
 
 // OnCommandGroup 命令触发器组
 func (e *Engine) OnCommandGroup(commands []string, rules ...Rule) IMatcher {
-	return e.On("message", append([]Rule{CommandRule(commands...)}, rules...)...)
+	matcher := &Matcher{
+		Type:    Type("message"),
+		Rules:   append([]Rule{CommandRule(commands...)}, rules...),
+		Engine:  e,
+		trigger: trigger{kind: TriggerCommand, typ: "message", literals: commands},
+	}
+	e.matchers = append(e.matchers, matcher)
+	return StoreMatcher(matcher)
 }
 
 // OnPrefixGroup 前缀触发器组
@@ -263,9 +291,10 @@ func OnPrefixGroup(prefix []string, rules ...Rule) IMatcher {
 // OnPrefixGroup 前缀触发器组
 func (e *Engine) OnPrefixGroup(prefix []string, rules ...Rule) IMatcher {
 	matcher := &Matcher{
-		Type:   Type("message"),
-		Rules:  append([]Rule{PrefixRule(prefix...)}, rules...),
-		Engine: e,
+		Type:    Type("message"),
+		Rules:   append([]Rule{PrefixRule(prefix...)}, rules...),
+		Engine:  e,
+		trigger: trigger{kind: TriggerPrefix, typ: "message", literals: prefix},
 	}
 	e.matchers = append(e.matchers, matcher)
 	return StoreMatcher(matcher)
@@ -279,9 +308,10 @@ func OnSuffixGroup(suffix []string, rules ...Rule) IMatcher {
 // OnSuffixGroup 后缀触发器组
 func (e *Engine) OnSuffixGroup(suffix []string, rules ...Rule) IMatcher {
 	matcher := &Matcher{
-		Type:   Type("message"),
-		Rules:  append([]Rule{SuffixRule(suffix...)}, rules...),
-		Engine: e,
+		Type:    Type("message"),
+		Rules:   append([]Rule{SuffixRule(suffix...)}, rules...),
+		Engine:  e,
+		trigger: trigger{kind: TriggerSuffix, typ: "message", literals: suffix},
 	}
 	e.matchers = append(e.matchers, matcher)
 	return StoreMatcher(matcher)
@@ -294,5 +324,12 @@ func OnShell(command string, model interface{}, rules ...Rule) IMatcher {
 
 // OnShell shell命令触发器
 func (e *Engine) OnShell(command string, model interface{}, rules ...Rule) IMatcher {
-	return e.On("message", append([]Rule{ShellRule(command, model)}, rules...)...)
+	matcher := &Matcher{
+		Type:    Type("message"),
+		Rules:   append(append([]Rule{shellRule(command, model, true)}, rules...), replyShellUsage),
+		Engine:  e,
+		trigger: trigger{kind: TriggerShell, typ: "message", literals: []string{command}},
+	}
+	e.matchers = append(e.matchers, matcher)
+	return StoreMatcher(matcher)
 }
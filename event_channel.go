@@ -1,5 +1,7 @@
 package zero
 
+import "sync"
+
 // FutureEvent 是 ZeroBot 交互式的核心，用于异步获取指定事件
 type FutureEvent struct {
 	Type     string
@@ -30,33 +32,79 @@ func (m *Matcher) FutureEvent(Type string, rule ...Rule) *FutureEvent {
 
 // Next 返回一个 chan 用于接收下一个指定事件
 //
-// 该 chan 必须接收，如需手动取消监听，请使用 Repeat 方法
+// 该 chan 必须接收, 如需在超时等情况下取消监听, 请使用 NextWithCancel;
+// Shutdown 时 chan 将被关闭, 此时收到 nil, 请以 ctx, ok := <-ch 检查
 func (n *FutureEvent) Next() <-chan Context {
+	ch, _ := n.NextWithCancel()
+	return ch
+}
+
+// NextWithCancel 同 Next, 并返回取消监听的函数
+//
+// 取消, 删除监听的 Matcher 或 Shutdown 时 chan 将被关闭;
+// 在 select 中等待超时后应调用 cancel, 否则监听将保留至收到事件或 Shutdown
+func (n *FutureEvent) NextWithCancel() (recv <-chan Context, cancel func()) {
 	ch := make(chan Context, 1)
-	StoreTempMatcher(&Matcher{
+	if !track() { // 已关闭
+		close(ch)
+		return ch, func() {}
+	}
+	var once sync.Once
+	send := func(ctx Context) {
+		once.Do(func() {
+			if ctx != nil {
+				ch <- ctx
+			}
+			close(ch)
+			inflight.Done()
+		})
+	}
+	matcher := &Matcher{
 		Type:     Type(n.Type),
+		trigger:  trigger{kind: TriggerFuture, typ: n.Type},
 		Block:    n.Block,
 		Priority: n.Priority,
 		Rules:    n.Rule,
 		Engine:   defaultEngine,
-		Handler: func(ctx Context) {
-			ch <- ctx
-			close(ch)
-		},
-	})
-	return ch
+		Handler:  send,
+		onDelete: func() { send(nil) }, // 匹配后删除时已发送, 不再关闭
+	}
+	StoreTempMatcher(matcher)
+	return ch, matcher.Delete
+}
+
+// deleteFutureMatchers Shutdown 时删除 Next 等待中的 Matcher, 关闭其 chan
+func deleteFutureMatchers() {
+	matcherLock.RLock()
+	var pending []*Matcher
+	for _, m := range matcherList {
+		if m, ok := m.(*Matcher); ok && m.Temp && m.trigger.kind == TriggerFuture {
+			pending = append(pending, m)
+		}
+	}
+	matcherLock.RUnlock()
+	for _, m := range pending {
+		m.Delete()
+	}
 }
 
 // Repeat 返回一个 chan 用于接收无穷个指定事件，和一个取消监听的函数
 //
-// 如果没有取消监听，将不断监听指定事件
+// 如果没有取消监听，将不断监听指定事件;
+// Shutdown 时 recv 将被关闭, 请以 ctx, ok := <-recv 检查
 func (n *FutureEvent) Repeat() (recv <-chan Context, cancel func()) {
 	ch, done := make(chan Context, 1), make(chan struct{})
+	if !track() { // 已关闭
+		close(ch)
+		return ch, func() {}
+	}
 	go func() {
+		defer inflight.Done()
 		defer close(ch)
 		in := make(chan Context, 1)
 		matcher := StoreMatcher(&Matcher{
 			Type:     Type(n.Type),
+			trigger:  trigger{kind: TriggerFuture, typ: n.Type},
 			Block:    n.Block,
 			Priority: n.Priority,
 			Rules:    n.Rule,
@@ -73,6 +121,9 @@ func (n *FutureEvent) Repeat() (recv <-chan Context, cancel func()) {
 				matcher.Delete()
 				close(in)
 				return
+			case <-shutdownch: // Shutdown 时结束监听
+				matcher.Delete()
+				return
 			}
 		}
 	}()
@@ -83,14 +134,19 @@ func (n *FutureEvent) Repeat() (recv <-chan Context, cancel func()) {
 
 // Take 基于 Repeat 封装，返回一个 chan 接收指定数量的事件
 //
-// 该 chan 对象必须接收，否则将有 goroutine 泄漏，如需手动取消请使用 Repeat
+// 该 chan 对象必须接收，否则将有 goroutine 泄漏，如需手动取消请使用 Repeat;
+// Shutdown 时 chan 将被关闭, 可能少于 num 个事件
 func (n *FutureEvent) Take(num int) <-chan Context {
 	recv, cancel := n.Repeat()
 	ch := make(chan Context, num)
 	go func() {
 		defer close(ch)
 		for i := 0; i < num; i++ {
-			ch <- <-recv
+			e, ok := <-recv
+			if !ok { // Shutdown
+				return
+			}
+			ch <- e
 		}
 		cancel()
 	}()
@@ -0,0 +1,53 @@
+package zero
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func futureMatchers(typ string) []IMatcher {
+	var ms []IMatcher
+	for _, info := range ListMatchers() {
+		if info.Kind == TriggerFuture && info.Type == typ {
+			ms = append(ms, info.Matcher)
+		}
+	}
+	return ms
+}
+
+func TestNextWithCancel(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+	recv, cancel := NewFutureEvent("message", 0, false).NextWithCancel()
+	assert.Len(t, futureMatchers("message"), 1)
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines) // 等待时不占用 goroutine
+	cancel()
+	_, ok := <-recv
+	assert.False(t, ok)
+	assert.Empty(t, futureMatchers("message"))
+	cancel() // 重复取消无操作
+
+	// 匹配后发送事件并删除 Matcher
+	recv = NewFutureEvent("message", 0, false).Next()
+	ctx := messageCtx("hi")
+	processMatchers(ctx, futureMatchers("message"), time.NewTimer(time.Second))
+	got, ok := <-recv
+	assert.True(t, ok)
+	assert.Equal(t, ctx, got)
+	_, ok = <-recv
+	assert.False(t, ok)
+	assert.Empty(t, futureMatchers("message"))
+
+	// 删除 Matcher 或 Shutdown 时关闭
+	recv = NewFutureEvent("message", 0, false).Next()
+	recv2 := NewFutureEvent("message", 0, false).Next()
+	futureMatchers("message")[0].Delete()
+	deleteFutureMatchers()
+	for _, ch := range []<-chan Context{recv, recv2} {
+		_, ok = <-ch
+		assert.False(t, ok)
+	}
+	assert.Empty(t, futureMatchers("message"))
+}
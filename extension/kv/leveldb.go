@@ -5,6 +5,8 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/util"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
 )
 
 var db *leveldb.DB
@@ -15,6 +17,12 @@ func init() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	zero.OnShutdown(Close)
+}
+
+// Close 写入并关闭数据库, 在 zero.Shutdown 时自动调用
+func Close() error {
+	return db.Close()
 }
 
 // Bucket is the interface of the database bucket
@@ -0,0 +1,135 @@
+// Package metrics 统计事件、Matcher 与 API 调用, 以 Prometheus 文本格式输出
+//
+//	http.Handle("/metrics", metrics.Enable())
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// Collector 收集运行指标, 实现 zero.Observer 与 http.Handler
+type Collector struct {
+	events   *vec
+	process  *vec
+	stages   *vec
+	timeouts *vec
+	apicalls *vec
+	apicost  *vec
+	names    sync.Map // handler pointer -> name
+}
+
+// New 创建 Collector, 需要通过 zero.SetObserver 与 zero.UseAPIInterceptor 注册
+func New() *Collector {
+	return &Collector{
+		events:   newVec("zerobot_events_total", "Number of events received.", counter, "self_id", "post_type", "detail_type"),
+		process:  newVec("zerobot_process_seconds", "Time spent processing all matchers of an event.", histogram, "post_type"),
+		stages:   newVec("zerobot_stage_seconds", "Time spent in each stage of a matcher.", histogram, "stage", "matcher"),
+		timeouts: newVec("zerobot_stage_timeouts_total", "Number of stages that reached MaxProcessTime.", counter, "stage", "matcher"),
+		apicalls: newVec("zerobot_api_calls_total", "Number of API calls by action and retcode.", counter, "action", "retcode"),
+		apicost:  newVec("zerobot_api_call_seconds", "Time spent in API calls.", histogram, "action"),
+	}
+}
+
+// Enable 创建 Collector 并注册到 zero, 须在 zero.Run 前调用
+func Enable() *Collector {
+	c := New()
+	zero.SetObserver(c)
+	zero.UseAPIInterceptor(c.Intercept)
+	return c
+}
+
+// ObserveEvent 实现 zero.Observer
+func (c *Collector) ObserveEvent(e *zero.Event) {
+	c.events.inc(strconv.FormatInt(e.SelfID, 10), e.PostType, e.DetailType)
+}
+
+// ObserveMatchers 实现 zero.Observer
+func (c *Collector) ObserveMatchers(e *zero.Event, cost time.Duration) {
+	c.process.observe(cost.Seconds(), e.PostType)
+}
+
+// ObserveStage 实现 zero.Observer
+func (c *Collector) ObserveStage(m zero.IMatcher, stage string, cost time.Duration) {
+	c.stages.observe(cost.Seconds(), stage, c.matcherName(m))
+}
+
+// ObserveTimeout 实现 zero.Observer
+func (c *Collector) ObserveTimeout(m zero.IMatcher, stage string) {
+	c.timeouts.inc(stage, c.matcherName(m))
+}
+
+// Intercept 作为 zero.APIInterceptor 统计 API 调用
+func (c *Collector) Intercept(next zero.APICaller) zero.APICaller {
+	return zero.APICallerFunc(func(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+		start := time.Now()
+		rsp, err := next.CallApiContext(ctx, req)
+		c.apicost.observe(time.Since(start).Seconds(), req.Action)
+		retcode := "error"
+		if err == nil {
+			retcode = strconv.FormatInt(rsp.RetCode, 10)
+		}
+		c.apicalls.inc(req.Action, retcode)
+		return rsp, err
+	})
+}
+
+// matcherName 使用 Handler 的函数名标识 Matcher
+func (c *Collector) matcherName(m zero.IMatcher) string {
+	if m == nil || m.GetHandler() == nil {
+		return "unknown"
+	}
+	pc := reflect.ValueOf(m.GetHandler()).Pointer()
+	if name, ok := c.names.Load(pc); ok {
+		return name.(string)
+	}
+	name := "unknown"
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		name = fn.Name()
+	}
+	c.names.Store(pc, name)
+	return name
+}
+
+// WriteTo 以 Prometheus 文本格式写出所有指标
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	for _, v := range [...]*vec{c.events, c.process, c.stages, c.timeouts, c.apicalls, c.apicost} {
+		v.write(cw)
+	}
+	err := cw.w.Flush()
+	if err == nil {
+		err = cw.err
+	}
+	return cw.n, err
+}
+
+// ServeHTTP 实现 http.Handler
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = c.WriteTo(w)
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) WriteString(s string) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.WriteString(s)
+	cw.n += int64(n)
+	cw.err = err
+}
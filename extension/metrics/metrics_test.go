@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+func TestCollector(t *testing.T) {
+	c := New()
+	c.ObserveEvent(&zero.Event{SelfID: 1, PostType: "message", DetailType: "group"})
+	c.ObserveEvent(&zero.Event{SelfID: 1, PostType: "message", DetailType: "group"})
+	c.ObserveMatchers(&zero.Event{PostType: "message"}, 30*time.Millisecond)
+	c.ObserveTimeout(nil, "handler")
+	c.apicalls.inc("send_msg", "0")
+
+	var sb strings.Builder
+	_, err := c.WriteTo(&sb)
+	assert.NoError(t, err)
+	out := sb.String()
+	assert.Contains(t, out, "# TYPE zerobot_events_total counter\n")
+	assert.Contains(t, out, `zerobot_events_total{self_id="1",post_type="message",detail_type="group"} 2`)
+	assert.Contains(t, out, `zerobot_process_seconds_bucket{post_type="message",le="0.025"} 0`)
+	assert.Contains(t, out, `zerobot_process_seconds_bucket{post_type="message",le="0.05"} 1`)
+	assert.Contains(t, out, `zerobot_process_seconds_count{post_type="message"} 1`)
+	assert.Contains(t, out, `zerobot_stage_timeouts_total{stage="handler",matcher="unknown"} 1`)
+	assert.Contains(t, out, `zerobot_api_calls_total{action="send_msg",retcode="0"} 1`)
+	assert.NotContains(t, out, "zerobot_stage_seconds")
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type kind uint8
+
+const (
+	counter kind = iota
+	histogram
+)
+
+// buckets 直方图的上界, 单位为秒
+var buckets = [...]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// vec 带标签的一组指标
+type vec struct {
+	name   string
+	help   string
+	kind   kind
+	labels []string
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values  []string
+	count   uint64
+	sum     float64
+	buckets [len(buckets)]uint64
+}
+
+func newVec(name, help string, k kind, labels ...string) *vec {
+	return &vec{
+		name:   name,
+		help:   help,
+		kind:   k,
+		labels: labels,
+		series: make(map[string]*series, 16),
+	}
+}
+
+func (v *vec) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: values}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (v *vec) inc(values ...string) {
+	v.mu.Lock()
+	v.get(values).count++
+	v.mu.Unlock()
+}
+
+func (v *vec) observe(x float64, values ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s := v.get(values)
+	s.count++
+	s.sum += x
+	for i, le := range buckets {
+		if x <= le {
+			s.buckets[i]++
+		}
+	}
+}
+
+func (v *vec) write(w *countWriter) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.series) == 0 {
+		return
+	}
+	typ := "counter"
+	if v.kind == histogram {
+		typ = "histogram"
+	}
+	w.WriteString("# HELP " + v.name + " " + v.help + "\n")
+	w.WriteString("# TYPE " + v.name + " " + typ + "\n")
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := v.series[k]
+		if v.kind == counter {
+			w.WriteString(v.name + v.labelString(s.values, "") + " " + strconv.FormatUint(s.count, 10) + "\n")
+			continue
+		}
+		for i, le := range buckets {
+			w.WriteString(v.name + "_bucket" + v.labelString(s.values, strconv.FormatFloat(le, 'g', -1, 64)) +
+				" " + strconv.FormatUint(s.buckets[i], 10) + "\n")
+		}
+		w.WriteString(v.name + "_bucket" + v.labelString(s.values, "+Inf") + " " + strconv.FormatUint(s.count, 10) + "\n")
+		w.WriteString(v.name + "_sum" + v.labelString(s.values, "") + " " + strconv.FormatFloat(s.sum, 'g', -1, 64) + "\n")
+		w.WriteString(v.name + "_count" + v.labelString(s.values, "") + " " + strconv.FormatUint(s.count, 10) + "\n")
+	}
+}
+
+// labelString 格式化标签, le 不为空时附加 le 标签
+func (v *vec) labelString(values []string, le string) string {
+	if len(v.labels) == 0 && le == "" {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, l := range v.labels {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(l)
+		sb.WriteString(`="`)
+		sb.WriteString(escape(values[i]))
+		sb.WriteByte('"')
+	}
+	if le != "" {
+		if len(v.labels) > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(`le="`)
+		sb.WriteString(le)
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,382 @@
+// Package sendqueue 发送队列, 按群与每个账号的总速率排队发送消息以避免风控
+//
+//	q := sendqueue.New(sendqueue.Config{GlobalRate: 2, GroupRate: 0.5, Jitter: time.Second})
+//	zero.UseAPIInterceptor(q.Intercept)
+package sendqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// OverflowPolicy 队列达到 MaxDepth 时的处理方式
+type OverflowPolicy uint8
+
+const (
+	// Reject 拒绝新消息, 返回 ErrQueueFull
+	Reject OverflowPolicy = iota
+	// DropOldest 丢弃最早排队的消息, 其调用返回 ErrDropped
+	DropOldest
+	// MergeForward 将排队的消息与新消息合并为一条合并转发消息, 频道消息按 DropOldest 处理
+	MergeForward
+)
+
+var (
+	// ErrQueueFull 队列已满
+	ErrQueueFull = errors.New("sendqueue: queue is full")
+	// ErrDropped 消息因队列溢出被丢弃
+	ErrDropped = errors.New("sendqueue: message dropped")
+)
+
+// Config 发送队列配置
+type Config struct {
+	GlobalRate  float64        // 每个账号每秒最多发送的消息数, 0 为不限制
+	GlobalBurst int            // 每个账号允许的突发消息数, 最小为 1
+	GroupRate   float64        // 每个群 (私聊为每个用户) 每秒最多发送的消息数, 0 为不限制
+	GroupBurst  int            // 每个群允许的突发消息数, 最小为 1
+	Jitter      time.Duration  // 每条消息发送前额外随机等待 [0, Jitter)
+	MaxDepth    int            // 每个群的队列长度上限, 0 为不限制
+	Overflow    OverflowPolicy // 达到 MaxDepth 时的处理方式
+}
+
+// Queue 发送队列
+//
+//	账号由 zero.SelfIDFromContext 获取, 不同账号的速率与队列互不影响
+type Queue struct {
+	cfg     Config
+	mu      sync.Mutex
+	global  map[int64]*pacer // self id -> 账号的总速率
+	targets map[targetKey]*target
+	pacers  map[targetKey]pacer // 队列已清空但仍未到下次发送时间的目标
+	swept   time.Time           // 上次清理 pacers 的时间
+	depth   int
+}
+
+// targetKey 账号与发送目标, 如 group:1 private:2 guild:3:4
+type targetKey struct {
+	selfID int64
+	dest   string
+}
+
+// New 创建发送队列
+func New(cfg Config) *Queue {
+	return &Queue{
+		cfg:     cfg,
+		global:  make(map[int64]*pacer, 4),
+		targets: make(map[targetKey]*target, 16),
+		pacers:  make(map[targetKey]pacer, 16),
+	}
+}
+
+type result struct {
+	rsp zero.APIResponse
+	err error
+}
+
+type item struct {
+	ctx   context.Context
+	req   zero.APIRequest
+	next  zero.APICaller
+	res   chan<- result
+	parts []*item // 合并转发的各条消息, 发送失败时逐条重新排队
+	split bool    // 合并转发失败后重新排队, 不再合并
+}
+
+func (it *item) reply(r result) {
+	if it.parts == nil {
+		it.res <- r
+		return
+	}
+	for _, p := range it.parts {
+		p.reply(r)
+	}
+}
+
+type target struct {
+	pacer   pacer
+	queue   []*item
+	running bool
+}
+
+// Intercept 作为 zero.APIInterceptor 使用, 发送消息的 API 将进入队列
+func (q *Queue) Intercept(next zero.APICaller) zero.APICaller {
+	return zero.APICallerFunc(func(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+		dest, ok := targetOf(req)
+		if !ok {
+			return next.CallApiContext(ctx, req)
+		}
+		ch := make(chan result, 1)
+		key := targetKey{selfID: zero.SelfIDFromContext(ctx), dest: dest}
+		err := q.enqueue(key, &item{ctx: ctx, req: req, next: next, res: ch})
+		if err != nil {
+			return zero.APIResponse{}, err
+		}
+		select {
+		case r := <-ch:
+			return r.rsp, r.err
+		case <-ctx.Done():
+			return zero.APIResponse{}, ctx.Err()
+		}
+	})
+}
+
+// Depth 所有群排队中的消息数
+func (q *Queue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.depth
+}
+
+// GroupDepth 群 groupID 排队中的消息数, 包含所有账号
+func (q *Queue) GroupDepth(groupID int64) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	dest, n := "group:"+strconv.FormatInt(groupID, 10), 0
+	for k, t := range q.targets {
+		if k.dest == dest {
+			n += len(t.queue)
+		}
+	}
+	return n
+}
+
+func (q *Queue) enqueue(key targetKey, it *item) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	t, ok := q.targets[key]
+	if !ok {
+		p, ok := q.pacers[key]
+		if ok {
+			delete(q.pacers, key)
+		} else {
+			p = newPacer(q.cfg.GroupRate, q.cfg.GroupBurst)
+		}
+		t = &target{pacer: p}
+		q.targets[key] = t
+	}
+	if q.cfg.MaxDepth > 0 && len(t.queue) >= q.cfg.MaxDepth {
+		policy := q.cfg.Overflow
+		if policy == MergeForward {
+			if merged := merge(key.dest, append(t.queue, it)); merged != nil {
+				q.depth -= len(t.queue) - 1
+				t.queue = append(t.queue[:0], merged)
+				return nil
+			}
+			policy = DropOldest
+		}
+		switch policy {
+		case DropOldest:
+			t.queue[0].reply(result{err: ErrDropped})
+			t.queue = t.queue[1:]
+			q.depth--
+		default:
+			return ErrQueueFull
+		}
+	}
+	t.queue = append(t.queue, it)
+	q.depth++
+	if !t.running {
+		t.running = true
+		go q.run(key, t)
+	}
+	return nil
+}
+
+// run 按速率依次发送 t 中的消息, 队列为空时退出
+func (q *Queue) run(key targetKey, t *target) {
+	for {
+		q.mu.Lock()
+		if len(t.queue) == 0 {
+			t.running = false
+			delete(q.targets, key)
+			now := time.Now()
+			if !t.pacer.idle(now) {
+				q.pacers[key] = t.pacer
+			}
+			q.sweep(now)
+			q.mu.Unlock()
+			return
+		}
+		it := t.queue[0]
+		t.queue[0] = nil
+		t.queue = t.queue[1:]
+		q.depth--
+		global, ok := q.global[key.selfID]
+		if !ok {
+			p := newPacer(q.cfg.GlobalRate, q.cfg.GlobalBurst)
+			global = &p
+			q.global[key.selfID] = global
+		}
+		at := global.reserve(t.pacer.reserve(time.Now()))
+		q.mu.Unlock()
+
+		wait := time.Until(at)
+		if q.cfg.Jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(q.cfg.Jitter)))
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-it.ctx.Done():
+				timer.Stop()
+			}
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.reply(result{err: err})
+			continue
+		}
+		var r result
+		r.rsp, r.err = it.next.CallApiContext(it.ctx, it.req)
+		if r.err != nil && it.parts != nil { // 合并转发失败, 逐条发送
+			q.requeue(t, it.parts)
+			continue
+		}
+		it.reply(r)
+	}
+}
+
+// sweep 删除 pacers 中已无需等待的目标, 每个限速周期最多清理一次
+func (q *Queue) sweep(now time.Time) {
+	p := newPacer(q.cfg.GroupRate, q.cfg.GroupBurst)
+	if now.Sub(q.swept) < time.Duration(p.burst)*p.interval {
+		return
+	}
+	q.swept = now
+	for k, p := range q.pacers {
+		if p.idle(now) {
+			delete(q.pacers, k)
+		}
+	}
+}
+
+// requeue 将合并转发的各条消息放回队首
+func (q *Queue) requeue(t *target, parts []*item) {
+	for _, p := range parts {
+		p.split = true
+	}
+	q.mu.Lock()
+	t.queue = append(parts, t.queue...)
+	q.depth += len(parts)
+	q.mu.Unlock()
+}
+
+// targetOf 获取发送消息请求的目标, 非发送消息的请求返回 false
+func targetOf(req zero.APIRequest) (string, bool) {
+	p := req.Params
+	switch req.Action {
+	case "send_group_msg", "send_group_forward_msg":
+		return "group:" + fmt.Sprint(p["group_id"]), true
+	case "send_private_msg", "send_private_forward_msg":
+		return "private:" + fmt.Sprint(p["user_id"]), true
+	case "send_msg":
+		if gid, ok := p["group_id"]; ok && p["message_type"] != "private" {
+			return "group:" + fmt.Sprint(gid), true
+		}
+		return "private:" + fmt.Sprint(p["user_id"]), true
+	case "send_guild_channel_msg":
+		return "guild:" + fmt.Sprint(p["guild_id"]) + ":" + fmt.Sprint(p["channel_id"]), true
+	case "send_message": // OneBot 12
+		switch p["detail_type"] {
+		case "group":
+			return "group:" + fmt.Sprint(p["group_id"]), true
+		case "private":
+			return "private:" + fmt.Sprint(p["user_id"]), true
+		case "channel":
+			return "guild:" + fmt.Sprint(p["guild_id"]) + ":" + fmt.Sprint(p["channel_id"]), true
+		}
+	}
+	return "", false
+}
+
+// merge 将 items 合并为一条合并转发消息, 使用最后一条消息的 ctx 发送
+//
+//	频道消息, OneBot 12 消息, 其中已有合并转发消息或合并转发失败的消息时返回 nil
+func merge(dest string, items []*item) *item {
+	last := items[len(items)-1]
+	var action string
+	params := zero.Params{}
+	switch {
+	case strings.HasPrefix(dest, "group:"):
+		action = "send_group_forward_msg"
+		params["group_id"] = last.req.Params["group_id"]
+	case strings.HasPrefix(dest, "private:"):
+		action = "send_private_forward_msg"
+		params["user_id"] = last.req.Params["user_id"]
+	default:
+		return nil
+	}
+	name := "ZeroBot"
+	if nicknames := zero.GetConfig().NickName; len(nicknames) > 0 {
+		name = nicknames[0]
+	}
+	selfID := zero.SelfIDFromContext(last.ctx)
+	nodes := make(message.Message, 0, len(items))
+	parts := make([]*item, 0, len(items))
+	for _, it := range items {
+		if it.split {
+			return nil
+		}
+		switch it.req.Action {
+		case "send_group_forward_msg", "send_private_forward_msg", "send_message":
+			return nil
+		}
+		nodes = append(nodes, message.CustomNode(name, selfID, it.req.Params["message"]))
+		parts = append(parts, it)
+	}
+	params["messages"] = nodes
+	return &item{
+		ctx:   last.ctx,
+		req:   zero.APIRequest{Action: action, Params: params},
+		next:  last.next,
+		parts: parts,
+	}
+}
+
+// pacer 令牌桶 (GCRA) 计算下一条消息的发送时间
+type pacer struct {
+	interval time.Duration
+	burst    int
+	tat      time.Time // theoretical arrival time
+}
+
+func newPacer(rate float64, burst int) pacer {
+	p := pacer{burst: burst}
+	if rate > 0 {
+		p.interval = time.Duration(float64(time.Second) / rate)
+	}
+	if p.burst < 1 {
+		p.burst = 1
+	}
+	return p
+}
+
+// reserve 预约不早于 now 的发送时间
+func (p *pacer) reserve(now time.Time) time.Time {
+	if p.interval <= 0 {
+		return now
+	}
+	if p.tat.Before(now) {
+		p.tat = now
+	}
+	at := p.tat.Add(-time.Duration(p.burst-1) * p.interval)
+	if at.Before(now) {
+		at = now
+	}
+	p.tat = p.tat.Add(p.interval)
+	return at
+}
+
+// idle 是否已无需等待
+func (p *pacer) idle(now time.Time) bool {
+	return !p.tat.After(now)
+}
@@ -0,0 +1,150 @@
+package sendqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+type recorder struct {
+	mu   sync.Mutex
+	reqs []zero.APIRequest
+	at   []time.Time
+}
+
+func (r *recorder) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
+	return r.CallApiContext(context.Background(), req)
+}
+
+func (r *recorder) CallApiContext(_ context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reqs = append(r.reqs, req)
+	r.at = append(r.at, time.Now())
+	return zero.APIResponse{Status: "ok"}, nil
+}
+
+func TestPacer(t *testing.T) {
+	p := newPacer(10, 2)
+	now := time.Now()
+	assert.Equal(t, now, p.reserve(now))
+	assert.Equal(t, now, p.reserve(now))
+	assert.Equal(t, now.Add(100*time.Millisecond), p.reserve(now))
+}
+
+func TestQueue(t *testing.T) {
+	r := &recorder{}
+	q := New(Config{GroupRate: 20, MaxDepth: 2, Overflow: MergeForward})
+	caller := q.Intercept(r)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := caller.CallApi(zero.APIRequest{Action: "send_group_msg", Params: zero.Params{"group_id": int64(1), "message": "hi"}})
+			assert.NoError(t, err)
+		}()
+		time.Sleep(5 * time.Millisecond)
+	}
+	wg.Wait()
+	assert.Equal(t, 0, q.Depth())
+	assert.Len(t, r.reqs, 3)
+	assert.Equal(t, "send_group_forward_msg", r.reqs[len(r.reqs)-1].Action)
+	for i := 1; i < len(r.at); i++ {
+		assert.GreaterOrEqual(t, r.at[i].Sub(r.at[i-1]), 45*time.Millisecond)
+	}
+}
+
+func TestQueueSelfID(t *testing.T) {
+	r := &recorder{}
+	q := New(Config{GlobalRate: 1})
+	caller := q.Intercept(r)
+	for _, id := range []int64{1, 2} {
+		zero.APICallers.Store(id, r)
+		defer zero.APICallers.Delete(id)
+	}
+	send := func(id int64, req zero.APIRequest) {
+		_, err := caller.CallApiContext(zero.GetBot(id).GetContext(), req)
+		assert.NoError(t, err)
+	}
+	start := time.Now()
+	send(1, zero.APIRequest{Action: "send_group_msg", Params: zero.Params{"group_id": int64(1), "message": "hi"}})
+	send(2, zero.APIRequest{Action: "send_message", Params: zero.Params{"detail_type": "group", "group_id": "1", "message": "hi"}})
+	assert.Less(t, time.Since(start), 500*time.Millisecond) // 不同账号的速率互不影响
+	dest, ok := targetOf(zero.APIRequest{Action: "send_message", Params: zero.Params{"detail_type": "private", "user_id": "2"}})
+	assert.True(t, ok)
+	assert.Equal(t, "private:2", dest)
+}
+
+type forwardFailer struct{ recorder }
+
+func (f *forwardFailer) CallApiContext(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	rsp, _ := f.recorder.CallApiContext(ctx, req)
+	if req.Action == "send_group_forward_msg" {
+		return zero.APIResponse{}, errors.New("forward unsupported")
+	}
+	return rsp, nil
+}
+
+func TestQueueMergeFallback(t *testing.T) {
+	f := &forwardFailer{}
+	q := New(Config{GroupRate: 20, MaxDepth: 2, Overflow: MergeForward})
+	caller := q.Intercept(f)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := caller.CallApi(zero.APIRequest{Action: "send_group_msg", Params: zero.Params{"group_id": int64(1), "message": "hi"}})
+			assert.NoError(t, err)
+		}()
+		time.Sleep(5 * time.Millisecond)
+	}
+	wg.Wait()
+	sent := 0
+	for _, req := range f.reqs {
+		if req.Action == "send_group_msg" {
+			sent++
+		}
+	}
+	assert.Equal(t, 4, sent) // 合并转发失败后逐条发送
+}
+
+func TestQueueTargetsShrink(t *testing.T) {
+	r := &recorder{}
+	q := New(Config{GroupRate: 50})
+	caller := q.Intercept(r)
+	send := func(gid int64) {
+		_, err := caller.CallApi(zero.APIRequest{Action: "send_group_msg", Params: zero.Params{"group_id": gid, "message": "hi"}})
+		assert.NoError(t, err)
+	}
+	var wg sync.WaitGroup
+	for i := int64(0); i < 100; i++ {
+		wg.Add(1)
+		go func(gid int64) {
+			defer wg.Done()
+			send(gid)
+		}(i)
+	}
+	wg.Wait()
+	assert.Eventually(t, func() bool { // 队列清空后删除
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return len(q.targets) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	time.Sleep(30 * time.Millisecond) // 超过 GroupRate 的间隔后, 下次发送时清理
+	send(1000)
+	assert.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return len(q.targets) == 0 && len(q.pacers) <= 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Len(t, r.reqs, 101)
+}
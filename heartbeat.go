@@ -0,0 +1,52 @@
+package zero
+
+import (
+	"time"
+
+	"github.com/RomiChan/syncx"
+	"github.com/tidwall/gjson"
+)
+
+// BotStatus 机器人最后一次心跳上报的状态
+type BotStatus struct {
+	SelfID   int64
+	Interval time.Duration // 心跳间隔
+	Time     time.Time     // 收到心跳的时间
+	Online   bool          // 是否在线, 未上报时视为在线
+	Good     bool          // 是否状态符合预期, 未上报时视为正常
+	Status   gjson.Result  // 心跳中的 status 对象, OneBot 12 下为 bots 中的对应项
+}
+
+var botstatus syncx.Map[int64, BotStatus]
+
+// ReportHeartbeat 由 Driver 在收到心跳元事件时调用, 更新 GetBotStatus 返回的状态
+func ReportHeartbeat(selfID int64, interval time.Duration, status gjson.Result) {
+	s := BotStatus{
+		SelfID:   selfID,
+		Interval: interval,
+		Time:     time.Now(),
+		Online:   true,
+		Good:     true,
+		Status:   status,
+	}
+	if v := status.Get("online"); v.Exists() {
+		s.Online = v.Bool()
+	}
+	if v := status.Get("good"); v.Exists() {
+		s.Good = v.Bool()
+	}
+	botstatus.Store(selfID, s)
+}
+
+// GetBotStatus 获取 selfID 最后一次心跳上报的状态, 未收到过心跳时返回 false
+//
+//	连接已断开 (不在 APICallers 中) 时 Online 为 false
+func GetBotStatus(selfID int64) (BotStatus, bool) {
+	s, ok := botstatus.Load(selfID)
+	if ok {
+		if _, connected := APICallers.Load(selfID); !connected {
+			s.Online = false
+		}
+	}
+	return s, ok
+}
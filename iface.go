@@ -1,6 +1,8 @@
 package zero
 
 import (
+	"context"
+
 	"github.com/tidwall/gjson"
 
 	"github.com/wdvxdr1123/ZeroBot/extension/rate"
@@ -9,6 +11,7 @@ import (
 
 // IEngineTrigger 是 ZeroBot 触发器的接口
 type IEngineTrigger interface {
+	Command(name string, rules ...Rule) *CommandNode
 	On(typ string, rules ...Rule) IMatcher
 	OnCommand(commands string, rules ...Rule) IMatcher
 	OnCommandGroup(commands []string, rules ...Rule) IMatcher
@@ -41,6 +44,8 @@ type IEngine interface {
 	IEngineHandler
 	Delete()
 	SetBlock(block bool) IEngine
+	SetName(name string) IEngine
+	GetName() string
 
 	getPreHandler() []Rule
 	getMidHandler() []Rule
@@ -86,6 +91,7 @@ type IMatcher interface {
 
 // ContextGetter ...
 type ContextGetter interface {
+	GetContext() context.Context
 	GetEvent() *Event
 	GetState() State
 }
@@ -96,9 +102,11 @@ type Context interface {
 	GoCQAPI
 	LLoneBotAPI
 	ContextGetter
+	API() API
 	Block()
 	Break()
 	CallAction(action string, params Params) APIResponse
+	CallActionV12(action string, params Params) APIResponse
 	CardOrNickName(uid int64) string
 	CheckSession() Rule
 	Echo(response []byte)
@@ -115,6 +123,7 @@ type Context interface {
 
 	setMatcher(matcher IMatcher)
 	getMatcher() IMatcher
+	cancelCalls()
 }
 
 // OneBotAPI OneBotAPI接口
@@ -162,6 +171,8 @@ type OneBotAPI interface {
 	GetRecord(file string, outFormat string) gjson.Result
 	GetImage(file string) gjson.Result
 	GetVersionInfo() gjson.Result
+	HandleQuickOperation(operation Params) APIResponse
+	QuickReply(reply interface{}, atSender bool) APIResponse
 }
 
 // GoCQAPI GoCQAPI接口
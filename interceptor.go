@@ -0,0 +1,96 @@
+package zero
+
+import (
+	"context"
+	"sync"
+)
+
+// APIInterceptor API 拦截器, 包装 next 并返回新的 APICaller
+//
+//	可在调用 next 前改写 APIRequest, 或不调用 next 直接返回 error 以拒绝本次调用
+//	触发本次调用的事件可通过 EventFromContext 获取
+type APIInterceptor func(next APICaller) APICaller
+
+var (
+	interceptors   []*APIInterceptor
+	interceptorsmu sync.RWMutex
+)
+
+// UseAPIInterceptor 注册 API 拦截器, 对之后创建的 Ctx 生效, 返回注销这些拦截器的函数
+//
+//	先注册的拦截器位于外层, 最先收到请求
+func UseAPIInterceptor(interceptor ...APIInterceptor) (remove func()) {
+	added := make([]*APIInterceptor, len(interceptor))
+	for i := range interceptor {
+		added[i] = &interceptor[i]
+	}
+	interceptorsmu.Lock()
+	interceptors = append(interceptors, added...)
+	interceptorsmu.Unlock()
+	return func() {
+		interceptorsmu.Lock()
+		defer interceptorsmu.Unlock()
+		kept := make([]*APIInterceptor, 0, len(interceptors))
+		for _, p := range interceptors {
+			if !containsInterceptor(added, p) {
+				kept = append(kept, p)
+			}
+		}
+		interceptors = kept
+	}
+}
+
+func containsInterceptor(s []*APIInterceptor, p *APIInterceptor) bool {
+	for _, x := range s {
+		if x == p {
+			return true
+		}
+	}
+	return false
+}
+
+// intercept 使用已注册的拦截器包装 caller
+func intercept(caller APICaller) APICaller {
+	interceptorsmu.RLock()
+	defer interceptorsmu.RUnlock()
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		caller = (*interceptors[i])(caller)
+	}
+	return caller
+}
+
+// APICallerFunc 将函数转换为 APICaller, 便于编写拦截器
+type APICallerFunc func(ctx context.Context, request APIRequest) (APIResponse, error)
+
+// CallApi 使用 context.Background() 调用 f
+func (f APICallerFunc) CallApi(request APIRequest) (APIResponse, error) {
+	return f(context.Background(), request)
+}
+
+// CallApiContext 调用 f
+func (f APICallerFunc) CallApiContext(ctx context.Context, request APIRequest) (APIResponse, error) {
+	return f(ctx, request)
+}
+
+type eventContextKey struct{}
+
+// EventFromContext 获取触发本次 API 调用的事件, 非事件触发的调用返回 nil
+func EventFromContext(ctx context.Context) *Event {
+	e, _ := ctx.Value(eventContextKey{}).(*Event)
+	return e
+}
+
+type selfContextKey struct{}
+
+// SelfIDFromContext 获取发起本次 API 调用的机器人账号, 未知时返回 0
+//
+//	事件触发的调用为事件的 SelfID, 通过 GetBot 或 RangeBot 发起的调用为其账号
+func SelfIDFromContext(ctx context.Context) int64 {
+	if id, ok := ctx.Value(selfContextKey{}).(int64); ok {
+		return id
+	}
+	if e := EventFromContext(ctx); e != nil {
+		return e.SelfID
+	}
+	return 0
+}
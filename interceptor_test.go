@@ -0,0 +1,48 @@
+package zero
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestAPIInterceptor(t *testing.T) {
+	errVeto := errors.New("veto")
+	var seen *Event
+	remove := UseAPIInterceptor(func(next APICaller) APICaller {
+		return APICallerFunc(func(ctx context.Context, req APIRequest) (APIResponse, error) {
+			switch req.Action {
+			case "test_veto":
+				seen = EventFromContext(ctx)
+				return APIResponse{}, errVeto
+			case "test_rewrite":
+				req.Action = "test_rewritten"
+			}
+			return next.CallApiContext(ctx, req)
+		})
+	})
+	t.Cleanup(remove)
+	e := &Event{UserID: 1}
+	ctx := &Ctx{Event: e, caller: intercept(mockCaller{
+		"test_rewritten": {Status: "ok", Data: gjson.Parse(`{"ok":true}`)},
+	})}
+	ctx.ctx = context.WithValue(context.Background(), eventContextKey{}, e)
+
+	err := ctx.API().Call("test_veto", Params{}, nil)
+	assert.ErrorIs(t, err, errVeto)
+	assert.Equal(t, e, seen)
+
+	var data struct {
+		OK bool `json:"ok"`
+	}
+	assert.NoError(t, ctx.API().Call("test_rewrite", Params{}, &data))
+	assert.True(t, data.OK)
+
+	remove()
+	interceptorsmu.RLock()
+	assert.Empty(t, interceptors)
+	interceptorsmu.RUnlock()
+}
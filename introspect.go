@@ -0,0 +1,91 @@
+package zero
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// MatcherInfo 已注册 Matcher 的描述
+type MatcherInfo struct {
+	Matcher  IMatcher
+	Kind     string   // 触发方式, 直接调用 StoreMatcher 时为空
+	Type     string   // 事件类型, 如 message notice/group_increase, 为空时匹配所有事件
+	Literals []string // 命令 前缀 后缀 关键词或完全匹配的字符串, 命令不含 CommandPrefix
+	Regex    string   // OnRegex 的表达式
+	Engine   IEngine
+	Service  string // Engine 的名称, control 注册的插件为服务名
+	Priority int
+	Block    bool
+	Temp     bool
+	Replaced bool   // 已通过 SetRules 替换规则, Literals 与 Regex 可能不再生效
+	File     string // 注册 Matcher 的源文件, 临时 Matcher 与 FutureEvent 为空
+	Line     int
+}
+
+// ListMatchers 返回所有已注册 Matcher 的描述, 按匹配顺序排列
+func ListMatchers() []MatcherInfo {
+	matcherLock.RLock()
+	defer matcherLock.RUnlock()
+	infos := make([]MatcherInfo, 0, len(matcherList))
+	for _, im := range matcherList {
+		info := MatcherInfo{
+			Matcher:  im,
+			Engine:   im.GetEngine(),
+			Priority: im.GetPriority(),
+			Block:    im.GetBlock(),
+			Temp:     im.GetTemp(),
+		}
+		if m, ok := im.(*Matcher); ok {
+			t := &m.trigger
+			info.Kind, info.Type, info.Regex = t.kind, t.typ, t.regex
+			info.Literals = append([]string(nil), t.literals...)
+			info.Replaced = t.replaced
+			info.File, info.Line = t.file, t.line
+		}
+		if info.Engine != nil {
+			info.Service = info.Engine.GetName()
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// frameworkDirs 注册 Matcher 时跳过的框架目录
+var frameworkDirs = func() []string {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return nil
+	}
+	root := filepath.Dir(file)
+	return []string{root, filepath.Join(root, "utils", "control")}
+}()
+
+// callerSite 返回调用栈中第一个框架外的位置
+func callerSite() (string, int) {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !inFramework(f.File) {
+			return f.File, f.Line
+		}
+		if !more {
+			return "", 0
+		}
+	}
+}
+
+func inFramework(file string) bool {
+	if strings.HasSuffix(file, "_test.go") {
+		return false
+	}
+	dir := filepath.Dir(filepath.FromSlash(file))
+	for _, d := range frameworkDirs {
+		if dir == d {
+			return true
+		}
+	}
+	return false
+}
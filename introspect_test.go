@@ -0,0 +1,52 @@
+package zero
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListMatchers(t *testing.T) {
+	e := New().SetName("ping")
+	defer e.Delete()
+	_, _, line, _ := runtime.Caller(0)
+	cmd := e.OnCommandGroup([]string{"ping", "p"}).SetPriority(3).SetBlock(true)
+	re := e.OnRegex(`^pong (\d+)$`)
+	notice := e.On("notice/group_increase")
+
+	infos := make(map[IMatcher]MatcherInfo)
+	for _, info := range ListMatchers() {
+		infos[info.Matcher] = info
+	}
+	info := infos[cmd]
+	assert.Equal(t, TriggerCommand, info.Kind)
+	assert.Equal(t, "message", info.Type)
+	assert.Equal(t, []string{"ping", "p"}, info.Literals)
+	assert.Equal(t, "ping", info.Service)
+	assert.Equal(t, 3, info.Priority)
+	assert.True(t, info.Block)
+	assert.Equal(t, "introspect_test.go", filepath.Base(info.File))
+	assert.Equal(t, line+1, info.Line)
+
+	assert.Equal(t, TriggerRegex, infos[re].Kind)
+	assert.Equal(t, `^pong (\d+)$`, infos[re].Regex)
+	assert.Equal(t, TriggerOn, infos[notice].Kind)
+	assert.Equal(t, "notice/group_increase", infos[notice].Type)
+
+	NewFutureEvent("notice/zerobot_test", 0, false).Next()
+	for _, info := range ListMatchers() {
+		if info.Kind == TriggerFuture && info.Type == "notice/zerobot_test" {
+			assert.Empty(t, info.File) // 不记录临时 Matcher 的注册位置
+			info.Matcher.Delete()
+		}
+	}
+
+	re.SetRules()
+	for _, info := range ListMatchers() {
+		if info.Matcher == re {
+			assert.True(t, info.Replaced)
+		}
+	}
+}
@@ -36,6 +36,11 @@ type Matcher struct {
 	Handler Handler
 	// Engine 注册 Matcher 的 Engine，Engine可为一系列 Matcher 添加通用 Rule 和 其他钩子
 	Engine IEngine
+
+	// trigger 注册时的触发方式
+	trigger trigger
+	// onDelete 从列表中删除后调用
+	onDelete func()
 }
 
 var (
@@ -43,11 +48,9 @@ var (
 	matcherList = make([]IMatcher, 0)
 	// Matcher 修改读写锁
 	matcherLock = sync.RWMutex{}
-	// 用于迭代的所有主匹配器列表
-	matcherListForRanging []IMatcher
 	// 是否 matcherList 已经改变
-	// 如果改变，下次迭代需要更新
-	// matcherListForRanging
+	// 如果改变，下次匹配前需要重建
+	// dispatchIndex
 	hasMatcherListChanged bool
 )
 
@@ -55,7 +58,7 @@ var (
 type State map[string]interface{}
 
 func sortMatcher() {
-	sort.Slice(matcherList, func(i, j int) bool { // 按优先级排序
+	sort.SliceStable(matcherList, func(i, j int) bool { // 按优先级排序, 相同优先级按注册顺序
 		return matcherList[i].GetPriority() < matcherList[j].GetPriority()
 	})
 	hasMatcherListChanged = true
@@ -119,8 +122,16 @@ func (m *Matcher) SetNoTimeout(b bool) IMatcher {
 }
 
 // SetRules 设置当前 Matcher 的匹配规则
+//
+//	将不再按 OnCommand 等注册时的字面量索引
 func (m *Matcher) SetRules(rules ...Rule) IMatcher {
+	matcherLock.Lock()
+	defer matcherLock.Unlock()
 	m.Rules = rules
+	if m.trigger.literal() {
+		hasMatcherListChanged = true
+	}
+	m.trigger.replaced = true
 	return m
 }
 
@@ -162,6 +173,10 @@ func (m *Matcher) BindEngine(e IEngine) IMatcher {
 
 // StoreMatcher store a matcher to matcher list.
 func StoreMatcher(m *Matcher) IMatcher {
+	// 临时 Matcher 与 FutureEvent 频繁注册, 不记录注册位置
+	if m.trigger.file == "" && !m.Temp && m.trigger.kind != TriggerFuture {
+		m.trigger.file, m.trigger.line = callerSite()
+	}
 	matcherLock.Lock()
 	defer matcherLock.Unlock()
 	// todo(wdvxdr): move to engine.
@@ -183,24 +198,33 @@ func StoreTempMatcher(m *Matcher) IMatcher {
 // Delete remove the matcher from list
 func (m *Matcher) Delete() {
 	matcherLock.Lock()
-	defer matcherLock.Unlock()
+	deleted := false
 	for i, matcher := range matcherList {
 		if m == matcher {
 			matcherList = append(matcherList[:i], matcherList[i+1:]...)
 			hasMatcherListChanged = true
+			deleted = true
 		}
 	}
+	matcherLock.Unlock()
+	if deleted && m.onDelete != nil {
+		m.onDelete()
+	}
 }
 
 func (m *Matcher) copy() *Matcher {
+	matcherLock.RLock()
+	defer matcherLock.RUnlock()
 	return &Matcher{
-		Type:     m.Type,
-		Rules:    m.Rules,
-		Block:    m.Block,
-		Priority: m.Priority,
-		Handler:  m.Handler,
-		Temp:     m.Temp,
-		Engine:   m.Engine,
+		Type:      m.Type,
+		Rules:     m.Rules,
+		Block:     m.Block,
+		Priority:  m.Priority,
+		Handler:   m.Handler,
+		Temp:      m.Temp,
+		Break:     m.Break,
+		NoTimeout: m.NoTimeout,
+		Engine:    m.Engine,
 	}
 }
 
@@ -212,7 +236,9 @@ func (m *Matcher) Handle(handler Handler) IMatcher {
 
 // Limit 限速器
 func (m *Matcher) Limit(limiterfn func(Context) *rate.Limiter, postfn ...func(Context)) IMatcher {
-	newRule := append(m.GetRules(), func(ctx Context) bool {
+	matcherLock.Lock()
+	defer matcherLock.Unlock()
+	m.Rules = append(m.Rules, func(ctx Context) bool {
 		if limiterfn(ctx).Acquire() {
 			return true
 		}
@@ -223,5 +249,6 @@ func (m *Matcher) Limit(limiterfn func(Context) *rate.Limiter, postfn ...func(Co
 		}
 		return false
 	})
-	return m.SetRules(newRule...)
+	hasMatcherListChanged = true // 仅追加规则, 保留字面量索引
+	return m
 }
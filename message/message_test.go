@@ -41,3 +41,30 @@ func TestMessageID(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestV12(t *testing.T) {
+	m := Message{At(123), AtAll(), Text("hi"), Record("a.silk")}
+	v12 := ToV12(m)
+	assert.Equal(t, "mention", v12[0].Type)
+	assert.Equal(t, "123", v12[0].Data["user_id"])
+	assert.Equal(t, "mention_all", v12[1].Type)
+	assert.Equal(t, "voice", v12[3].Type)
+	assert.Equal(t, "a.silk", v12[3].Data["file_id"])
+	assert.Equal(t, m.String(), FromV12(v12).String())
+}
+
+func TestSplit(t *testing.T) {
+	m := Message{Reply(1), At(2), Text("0123456789"), Image("a.png"), Text("ab\ncdefg")}
+	ret := Split(m, 8, 0)
+	assert.Equal(t, 3, len(ret))
+	assert.Equal(t, "reply", ret[0][0].Type)
+	assert.Equal(t, "at", ret[0][1].Type)
+	assert.Equal(t, "01234567", ret[0][2].Data["text"])
+	assert.Equal(t, "89", ret[1][0].Data["text"])
+	assert.Equal(t, "ab\n", ret[1][2].Data["text"])
+	assert.Equal(t, "cdefg", ret[2][0].Data["text"])
+	for _, c := range ret {
+		assert.LessOrEqual(t, c.TextLen(), 8)
+	}
+	assert.Equal(t, 2, len(Split(m, 0, 3)))
+}
@@ -0,0 +1,81 @@
+package message
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// TextLen 消息中纯文本的字符数
+func (m Message) TextLen() int {
+	n := 0
+	for _, seg := range m {
+		if seg.Type == "text" {
+			n += utf8.RuneCountInString(seg.Data["text"])
+		}
+	}
+	return n
+}
+
+// Split 在消息段边界将 m 拆分为文本不超过 maxLen 个字符, 且不超过 maxSegs 个消息段的多条消息
+//
+//	超长的纯文本段优先在换行处切开, 回复与 @ 等其它消息段保持原位, 不会复制到后续消息
+//	maxLen 或 maxSegs 为 0 时不限制该项
+func Split(m Message, maxLen, maxSegs int) []Message {
+	var (
+		ret  []Message
+		cur  Message
+		size int
+	)
+	flush := func() {
+		if len(cur) > 0 {
+			ret = append(ret, cur)
+		}
+		cur, size = nil, 0
+	}
+	for _, seg := range m {
+		if maxSegs > 0 && len(cur) >= maxSegs {
+			flush()
+		}
+		if seg.Type != "text" || maxLen <= 0 {
+			cur = append(cur, seg)
+			continue
+		}
+		text := seg.Data["text"]
+		for text != "" {
+			n := utf8.RuneCountInString(text)
+			if size+n <= maxLen {
+				cur = append(cur, Text(text))
+				size += n
+				break
+			}
+			if size >= maxLen {
+				flush()
+				continue
+			}
+			head, tail, atNewline := cutText(text, maxLen-size)
+			if !atNewline && size > 0 { // 无法在换行处切开时, 整段移至下一条消息
+				flush()
+				continue
+			}
+			cur = append(cur, Text(head))
+			flush()
+			text = tail
+		}
+	}
+	flush()
+	return ret
+}
+
+// cutText 在前 n 个字符内切开 s, 优先在最后一个换行后切开
+func cutText(s string, n int) (head, tail string, atNewline bool) {
+	i, c := 0, 0
+	for i < len(s) && c < n {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		c++
+	}
+	if nl := strings.LastIndexByte(s[:i], '\n'); nl >= 0 {
+		return s[:nl+1], s[nl+1:], true
+	}
+	return s[:i], s[i:], false
+}
@@ -0,0 +1,97 @@
+package message
+
+// OneBot 12 消息段与 OneBot 11 消息段的类型对应
+// https://12.onebot.dev/interface/message/segments/
+var (
+	v12ToV11Type = map[string]string{
+		"mention":     "at",
+		"mention_all": "at",
+		"voice":       "record",
+	}
+	v11ToV12Type = map[string]string{
+		"record": "voice",
+	}
+	// v12ToV11Key 各类型消息段中需要改名的字段
+	v12ToV11Key = map[string]map[string]string{
+		"mention":  {"user_id": "qq"},
+		"image":    {"file_id": "file"},
+		"voice":    {"file_id": "file"},
+		"video":    {"file_id": "file"},
+		"file":     {"file_id": "file"},
+		"reply":    {"message_id": "id"},
+		"location": {"latitude": "lat", "longitude": "lon"},
+	}
+	v11ToV12Key = map[string]map[string]string{
+		"image":    {"file": "file_id"},
+		"record":   {"file": "file_id"},
+		"video":    {"file": "file_id"},
+		"file":     {"file": "file_id"},
+		"reply":    {"id": "message_id"},
+		"location": {"lat": "latitude", "lon": "longitude"},
+	}
+)
+
+// OneBot 12 mention 消息段的 user_id 与 OneBot 11 at 消息段的 qq 的相互转换
+//
+//	zero 将其设置为 ParseV12ID 与 FormatV12ID, 使非数字 ID 在 at 消息段中为数字
+var (
+	V12UserIDToV11 = func(id string) string { return id }
+	V12UserIDToV12 = func(qq string) string { return qq }
+)
+
+// FromV12 将 OneBot 12 消息转换为 OneBot 11 消息
+func FromV12(m Message) Message {
+	ret := make(Message, 0, len(m))
+	for _, seg := range m {
+		typ := seg.Type
+		data := renameKeys(seg.Data, v12ToV11Key[typ])
+		switch typ {
+		case "mention":
+			data["qq"] = V12UserIDToV11(data["qq"])
+		case "mention_all":
+			data["qq"] = "all"
+		}
+		if t, ok := v12ToV11Type[typ]; ok {
+			typ = t
+		}
+		ret = append(ret, MessageSegment{Type: typ, Data: data})
+	}
+	return ret
+}
+
+// ToV12 将 OneBot 11 消息转换为 OneBot 12 消息
+func ToV12(m Message) Message {
+	ret := make(Message, 0, len(m))
+	for _, seg := range m {
+		typ := seg.Type
+		var data map[string]string
+		switch typ {
+		case "at":
+			if seg.Data["qq"] == "all" {
+				ret = append(ret, MessageSegment{Type: "mention_all", Data: map[string]string{}})
+				continue
+			}
+			typ = "mention"
+			data = renameKeys(seg.Data, map[string]string{"qq": "user_id"})
+			data["user_id"] = V12UserIDToV12(data["user_id"])
+		default:
+			data = renameKeys(seg.Data, v11ToV12Key[typ])
+			if t, ok := v11ToV12Type[typ]; ok {
+				typ = t
+			}
+		}
+		ret = append(ret, MessageSegment{Type: typ, Data: data})
+	}
+	return ret
+}
+
+func renameKeys(data map[string]string, keys map[string]string) map[string]string {
+	ret := make(map[string]string, len(data))
+	for k, v := range data {
+		if nk, ok := keys[k]; ok {
+			k = nk
+		}
+		ret[k] = v
+	}
+	return ret
+}
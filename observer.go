@@ -0,0 +1,31 @@
+package zero
+
+import "time"
+
+// Observer 事件处理过程的观察者, 用于统计运行指标, 参见 extension/metrics
+type Observer interface {
+	// ObserveEvent 收到并解析事件后调用
+	ObserveEvent(e *Event)
+	// ObserveMatchers 一个事件的所有 Matcher 处理结束后调用
+	ObserveMatchers(e *Event, cost time.Duration)
+	// ObserveStage Matcher 的一个处理阶段结束后调用
+	//
+	//	stage: preHandler rule midHandler handler postHandler
+	ObserveStage(m IMatcher, stage string, cost time.Duration)
+	// ObserveTimeout 处理达到最大时延时调用
+	ObserveTimeout(m IMatcher, stage string)
+}
+
+// observer 为 nil 时不统计
+var observer Observer
+
+// SetObserver 设置事件处理过程的观察者, 须在 Run 前调用
+func SetObserver(o Observer) {
+	observer = o
+}
+
+func observeStage(ctx Context, stage string, start time.Time) {
+	if observer != nil {
+		observer.ObserveStage(ctx.getMatcher(), stage, time.Since(start))
+	}
+}
@@ -0,0 +1,206 @@
+package zero
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrNotRunning 尚未调用 Run 或 RunAndBlock
+var ErrNotRunning = errors.New("zero: bot is not running")
+
+// updatemu 串行化 UpdateConfig
+var updatemu sync.Mutex
+
+// UpdateConfig 在不重启的情况下应用新配置
+//
+//	除 Driver 外的字段一次性替换, 之后开始处理的事件使用新值,
+//	RingLen 与 ring 模式下的 Latency 无法在运行中修改, 将保留原值.
+//	c.Driver 为 nil 时保持现有的 Driver, 否则连接新增的 Driver 并关闭被移除的 Driver.
+//	有字段变化时, 向每个在线的机器人分发 meta_event_type 为 config 的元事件,
+//	RawEvent 中的 changed 为变化字段的 json 名称, 可通过 OnMetaEvent(Type("meta_event/config")) 处理
+func UpdateConfig(c *Config) error {
+	updatemu.Lock()
+	defer updatemu.Unlock()
+	if err := c.validate(); err != nil {
+		return err
+	}
+	configmu.Lock()
+	if linkf == nil {
+		configmu.Unlock()
+		return ErrNotRunning
+	}
+	old, link := BotConfig, linkf
+	n := *c
+	if n.MaxProcessTime == 0 {
+		n.MaxProcessTime = time.Minute * 4
+	}
+	if n.RingLen != old.RingLen {
+		log.Warnf("[config] ring_len 无法在运行中修改, 保留原值 %d", old.RingLen)
+		n.RingLen = old.RingLen
+	}
+	if old.RingLen != 0 && n.Latency != old.Latency {
+		log.Warnf("[config] ring 模式下 latency 无法在运行中修改, 保留原值 %v", old.Latency)
+		n.Latency = old.Latency
+	}
+	var added, removed []Driver
+	if n.Driver == nil {
+		n.Driver = old.Driver
+	} else {
+		n.Driver = append([]Driver(nil), n.Driver...)
+		added, removed = diffDrivers(old.Driver, n.Driver)
+	}
+	BotConfig = n
+	configmu.Unlock()
+
+	for _, d := range removed {
+		if err := d.Close(); err != nil {
+			log.Warnln("[config] 关闭被移除的 Driver 时出现错误:", err)
+		}
+	}
+	for _, d := range added {
+		go func(d Driver) {
+			d.Connect()
+			d.Listen(link)
+		}(d)
+	}
+	changed := changedFields(&old, &n)
+	if len(added) > 0 || len(removed) > 0 {
+		changed = append(changed, "driver")
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	log.Infof("[config] 已更新配置: %s (新增 %d 个 Driver, 移除 %d 个 Driver)", strings.Join(changed, ", "), len(added), len(removed))
+	emitConfigChanged(changed, link)
+	return nil
+}
+
+// sameDriver 比较 Driver 是否为同一实例, 不可比较的类型视为不同
+func sameDriver(a, b Driver) bool {
+	if a == nil || b == nil || reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+func containsDriver(list []Driver, d Driver) bool {
+	for _, x := range list {
+		if sameDriver(x, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// diffDrivers 返回 n 中新增与 old 中被移除的 Driver, 被移除的 Driver 不再由 LoadConfig 沿用
+//
+//	调用时持有 configmu
+func diffDrivers(old, n []Driver) (added, removed []Driver) {
+	for _, d := range n {
+		if !containsDriver(old, d) {
+			added = append(added, d)
+		}
+	}
+	for _, d := range old {
+		if !containsDriver(n, d) {
+			removed = append(removed, d)
+			forgetDriver(d)
+		}
+	}
+	return
+}
+
+// changedFields 返回值发生变化的字段的 json 名称, 不含 Driver
+func changedFields(old, n *Config) []string {
+	var changed []string
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(n).Elem()
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// emitConfigChanged 向每个在线的机器人分发配置变化元事件
+func emitConfigChanged(changed []string, link func([]byte, APICaller)) {
+	APICallers.Range(func(id int64, caller APICaller) bool {
+		b, err := json.Marshal(H{
+			"time":            time.Now().Unix(),
+			"self_id":         id,
+			"post_type":       "meta_event",
+			"meta_event_type": "config",
+			"sub_type":        "changed",
+			"changed":         changed,
+		})
+		if err == nil {
+			link(b, caller)
+		}
+		return true
+	})
+}
+
+// waitDetached RunAndBlock 阻塞的 Driver 被 UpdateConfig 移除时, 继续阻塞直到 Shutdown
+func waitDetached(d Driver) {
+	if !containsDriver(GetConfig().Driver, d) {
+		<-shutdownch
+	}
+}
+
+// WatchConfig 每隔 interval 检查配置文件, 变化时重新读取并调用 UpdateConfig
+//
+//	未变化的 driver 项沿用正在运行的 Driver, 读取失败时保留当前配置.
+//	在 Run 前调用时, 首次变化前需已调用 Run. 返回的函数用于停止检查, Shutdown 时自动停止
+func WatchConfig(path string, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		interval = time.Second * 2
+	}
+	done := make(chan struct{})
+	var once sync.Once
+	stat := func() (time.Time, int64) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return fi.ModTime(), fi.Size()
+	}
+	mod, size := stat()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			case <-shutdownch:
+				return
+			}
+			m, s := stat()
+			if s < 0 || m.Equal(mod) && s == size {
+				continue
+			}
+			mod, size = m, s
+			c, err := LoadConfig(path)
+			if err == nil {
+				err = UpdateConfig(c)
+			}
+			if err != nil {
+				log.Warnln("[config] 重新加载配置失败:", err)
+			}
+		}
+	}()
+	return func() { once.Do(func() { close(done) }) }
+}
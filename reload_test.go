@@ -0,0 +1,114 @@
+package zero
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDriver struct {
+	connected chan struct{}
+	closed    chan struct{}
+}
+
+func newFakeDriver() *fakeDriver {
+	return &fakeDriver{connected: make(chan struct{}), closed: make(chan struct{})}
+}
+
+func (d *fakeDriver) Connect()                       { close(d.connected) }
+func (d *fakeDriver) Listen(func([]byte, APICaller)) { <-d.closed }
+func (d *fakeDriver) Close() error {
+	close(d.closed)
+	return nil
+}
+
+func TestUpdateConfig(t *testing.T) {
+	assert.ErrorIs(t, UpdateConfig(&Config{}), ErrNotRunning)
+
+	d1, d2 := newFakeDriver(), newFakeDriver()
+	Run(&Config{CommandPrefix: "/", Driver: []Driver{d1}})
+	defer func() {
+		configmu.Lock()
+		BotConfig, linkf = Config{}, nil
+		configmu.Unlock()
+		atomic.StoreUintptr(&isrunning, 0)
+		_ = d2.Close()
+	}()
+	<-d1.connected
+
+	APICallers.Store(30101, mockCaller{})
+	defer APICallers.Delete(30101)
+	changed := make(chan []string, 1)
+	m := OnMetaEvent(Type("meta_event/config/changed")).Handle(func(ctx Context) {
+		var fields []string
+		for _, f := range ctx.GetEvent().RawEvent.Get("changed").Array() {
+			fields = append(fields, f.Str)
+		}
+		changed <- fields
+	})
+	defer m.Delete()
+
+	assert.NoError(t, UpdateConfig(&Config{CommandPrefix: "#", NickName: []string{"bot"}, Driver: []Driver{d2}}))
+	<-d1.closed
+	<-d2.connected
+	select {
+	case fields := <-changed:
+		assert.Equal(t, []string{"nickname", "command_prefix", "driver"}, fields)
+	case <-time.After(time.Second):
+		t.Fatal("config event not received")
+	}
+	cfg := GetConfig()
+	assert.Equal(t, "#", cfg.CommandPrefix)
+	assert.Equal(t, 4*time.Minute, cfg.MaxProcessTime)
+	assert.Equal(t, []Driver{d2}, cfg.Driver)
+
+	// Driver 为 nil 时保持不变, 没有变化时不分发事件
+	assert.NoError(t, UpdateConfig(&Config{CommandPrefix: "#", NickName: []string{"bot"}}))
+	assert.Equal(t, []Driver{d2}, GetConfig().Driver)
+	select {
+	case <-changed:
+		t.Fatal("unexpected config event")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var cerr *ConfigError
+	assert.ErrorAs(t, UpdateConfig(&Config{MaxMessageLen: -1}), &cerr)
+	assert.Equal(t, "max_message_len", cerr.Field)
+}
+
+func TestLoadedDriverKey(t *testing.T) {
+	RegisterDriver("zb_fake", func(DriverConfig) (Driver, error) { return newFakeDriver(), nil })
+	entry := map[string]interface{}{"type": "zb_fake", "url": "ws://${ZB_FAKE_HOST}"}
+	t.Setenv("ZB_FAKE_HOST", "a")
+	d1, err := newDriver("driver[0]", entry)
+	assert.NoError(t, err)
+	configmu.Lock()
+	old := BotConfig.Driver
+	BotConfig.Driver = []Driver{d1}
+	configmu.Unlock()
+	defer func() {
+		configmu.Lock()
+		BotConfig.Driver = old
+		configmu.Unlock()
+	}()
+
+	d2, err := newDriver("driver[0]", entry)
+	assert.NoError(t, err)
+	assert.Same(t, d1, d2) // 替换后相同, 沿用
+	t.Setenv("ZB_FAKE_HOST", "b")
+	d3, err := newDriver("driver[0]", entry)
+	assert.NoError(t, err)
+	assert.NotSame(t, d1, d3) // 环境变量变化后重新创建
+
+	added, removed := diffDrivers([]Driver{d1}, []Driver{d3})
+	assert.Equal(t, []Driver{d3}, added)
+	assert.Equal(t, []Driver{d1}, removed)
+	loadedDriversMu.Lock()
+	defer loadedDriversMu.Unlock()
+	for _, d := range loadedDrivers {
+		assert.NotSame(t, d1, d) // 被移除的 Driver 不再保留
+	}
+	assert.Contains(t, loadedDrivers, `{"type":"zb_fake","url":"ws://b"}`)
+}
@@ -14,6 +14,9 @@ type eventRing struct {
 	r []*eventRingItem
 	i uintptr
 	p []eventRingItem
+
+	done    chan struct{} // 关闭时停止 loop
+	stopped bool          // 已调用 stop, 不再接收事件
 }
 
 type eventRingItem struct {
@@ -30,17 +33,26 @@ func newring(ringLen uint) eventRing {
 
 // processEvent 同步向池中放入事件
 //
+//	覆盖尚未处理的事件时, 被丢弃事件的 caller 将收到 EventFinished
+//
 //go:nosplit
 func (evr *eventRing) processEvent(response []byte, caller APICaller) {
 	evr.Lock()
 	defer evr.Unlock()
+	if evr.stopped {
+		finishEvent(caller)
+		return
+	}
 	r := evr.c % uintptr(len(evr.r))
 	p := evr.i % uintptr(len(evr.p))
 	evr.p[p] = eventRingItem{
 		response: response,
 		caller:   caller,
 	}
-	atomic.StorePointer((*unsafe.Pointer)(unsafe.Pointer(&evr.r[r])), unsafe.Pointer(&evr.p[p]))
+	old := (*eventRingItem)(atomic.SwapPointer((*unsafe.Pointer)(unsafe.Pointer(&evr.r[r])), unsafe.Pointer(&evr.p[p])))
+	if old != nil { // 未及处理, 丢弃
+		finishEvent(old.caller)
+	}
 	evr.c++
 	evr.i++
 }
@@ -49,23 +61,52 @@ func (evr *eventRing) processEvent(response []byte, caller APICaller) {
 //
 //	latency 延迟 latency 再处理事件
 func (evr *eventRing) loop(latency, maxwait time.Duration, process func([]byte, APICaller, time.Duration)) {
-	go func(r []*eventRingItem) {
+	evr.done = make(chan struct{})
+	go func(r []*eventRingItem, done <-chan struct{}) {
 		c := uintptr(0)
 		if latency < time.Millisecond {
 			latency = time.Millisecond
 		}
-		for range time.NewTicker(latency).C {
+		ticker := time.NewTicker(latency)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
 			i := c % uintptr(len(r))
-			it := (*eventRingItem)(atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(&r[i]))))
+			slot := (*unsafe.Pointer)(unsafe.Pointer(&r[i]))
+			it := (*eventRingItem)(atomic.LoadPointer(slot))
 			if it == nil { // 还未有消息
 				continue
 			}
-			process(it.response, it.caller, maxwait)
-			atomic.StorePointer((*unsafe.Pointer)(unsafe.Pointer(&r[i])), unsafe.Pointer(nil))
+			response, caller := it.response, it.caller
+			if !atomic.CompareAndSwapPointer(slot, unsafe.Pointer(it), nil) { // 已被新事件覆盖, 下次处理
+				continue
+			}
 			it.response = nil
 			it.caller = nil
+			process(response, caller, maxwait)
 			c++
 			runtime.GC()
 		}
-	}(evr.r)
+	}(evr.r, evr.done)
+}
+
+// stop 停止 loop, 未处理的事件视为丢弃, 未调用 loop 时无操作
+func (evr *eventRing) stop() {
+	evr.Lock()
+	defer evr.Unlock()
+	if evr.done != nil {
+		close(evr.done)
+		evr.done = nil
+		evr.stopped = true
+	}
+	for i := range evr.r {
+		it := (*eventRingItem)(atomic.SwapPointer((*unsafe.Pointer)(unsafe.Pointer(&evr.r[i])), nil))
+		if it != nil {
+			finishEvent(it.caller)
+		}
+	}
 }
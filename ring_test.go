@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 var buf [256]byte
@@ -46,3 +48,35 @@ func testProcess(response []byte, _ APICaller, _ time.Duration) {
 	buf[response[0]] = response[1]
 	fmt.Println(response[0], "processed")
 }
+
+// finishCaller 记录 EventFinished 的调用
+type finishCaller struct {
+	mockCaller
+	finished chan struct{}
+}
+
+func (c *finishCaller) EventFinished() { close(c.finished) }
+
+func TestRingFinishDropped(t *testing.T) {
+	newCaller := func() *finishCaller { return &finishCaller{finished: make(chan struct{})} }
+	finished := func(c *finishCaller) bool {
+		select {
+		case <-c.finished:
+			return true
+		default:
+			return false
+		}
+	}
+	r := newring(1)
+	r.loop(time.Hour, 0, func([]byte, APICaller, time.Duration) { t.Fatal("unexpected process") })
+	c1, c2, c3 := newCaller(), newCaller(), newCaller()
+	r.processEvent(nil, c1)
+	assert.False(t, finished(c1))
+	r.processEvent(nil, c2) // 覆盖未处理的 c1
+	assert.True(t, finished(c1))
+	assert.False(t, finished(c2))
+	r.stop() // 未处理的 c2 被丢弃
+	assert.True(t, finished(c2))
+	r.processEvent(nil, c3) // stop 后立即丢弃
+	assert.True(t, finished(c3))
+}
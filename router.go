@@ -0,0 +1,303 @@
+package zero
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// ErrNoRoute 没有可向该群发送消息的机器人
+var ErrNoRoute = errors.New("zero: no bot available for group")
+
+// RouteStrategy 多账号发送策略, 返回向群 gid 发送时尝试 bots 的顺序
+//
+//	bots 为在该群内、在线且未被禁言的机器人, 按 ID 升序
+type RouteStrategy func(gid int64, bots []int64) []int64
+
+// RoutePrimary 优先使用 primary 中靠前的机器人, 其余按 ID 升序
+func RoutePrimary(primary ...int64) RouteStrategy {
+	rank := make(map[int64]int, len(primary))
+	for i, id := range primary {
+		rank[id] = i - len(primary) // 负数, 排在未列出的机器人前
+	}
+	return func(_ int64, bots []int64) []int64 {
+		sort.SliceStable(bots, func(i, j int) bool {
+			return rank[bots[i]] < rank[bots[j]]
+		})
+		return bots
+	}
+}
+
+// RouteRoundRobin 每个群轮流使用各机器人
+func RouteRoundRobin() RouteStrategy {
+	var (
+		mu   sync.Mutex
+		next = make(map[int64]int)
+	)
+	return func(gid int64, bots []int64) []int64 {
+		mu.Lock()
+		n := next[gid] % len(bots)
+		next[gid] = n + 1
+		mu.Unlock()
+		return append(bots[n:], bots[:n]...)
+	}
+}
+
+// RouteLeastLoaded 优先使用最近一分钟内经 SendToGroup 发送消息最少的机器人
+func RouteLeastLoaded() RouteStrategy {
+	return func(_ int64, bots []int64) []int64 {
+		loads := make(map[int64]int, len(bots))
+		for _, id := range bots {
+			loads[id] = routes.load(id)
+		}
+		sort.SliceStable(bots, func(i, j int) bool {
+			return loads[bots[i]] < loads[bots[j]]
+		})
+		return bots
+	}
+}
+
+// SetRouteStrategy 设置 SendToGroup 的发送策略, 默认为 RoutePrimary()
+func SetRouteStrategy(s RouteStrategy) {
+	routes.mu.Lock()
+	routes.strategy = s
+	routes.mu.Unlock()
+}
+
+// SendToGroup 选择在群 gid 内的机器人发送消息, 发送失败时依次尝试其它机器人
+//
+//	群成员关系由 get_group_list 与群成员变动通知维护, 被禁言的机器人不参与选择
+func SendToGroup(gid int64, msg interface{}) (message.MessageID, error) {
+	bots := routes.candidates(gid)
+	if len(bots) == 0 {
+		return message.MessageID{}, ErrNoRoute
+	}
+	var err error
+	for _, id := range bots {
+		bot := GetBot(id)
+		if bot == nil { // 已离线
+			continue
+		}
+		var mid message.MessageID
+		mid, err = bot.API().SendGroupMessage(gid, msg)
+		if err == nil {
+			routes.sent(id)
+			return mid, nil
+		}
+		log.Warnf("[route] 账号 %d 向群 %d 发送消息失败: %v, 尝试其它账号", id, gid, err)
+	}
+	if err == nil {
+		err = ErrNoRoute
+	}
+	return message.MessageID{}, err
+}
+
+// GroupBots 返回已知在群 gid 内的所有机器人
+func GroupBots(gid int64) []int64 {
+	routes.mu.RLock()
+	defer routes.mu.RUnlock()
+	bots := make([]int64, 0, len(routes.groups[gid]))
+	for id := range routes.groups[gid] {
+		bots = append(bots, id)
+	}
+	sort.Slice(bots, func(i, j int) bool { return bots[i] < bots[j] })
+	return bots
+}
+
+// RefreshGroups 通过 get_group_list 重新获取 selfID 所在的群, selfID 为 0 时刷新所有机器人
+func RefreshGroups(selfID int64) {
+	RangeBot(func(id int64, ctx Context) bool {
+		if selfID == 0 || id == selfID {
+			routes.refresh(id, ctx)
+		}
+		return true
+	})
+}
+
+// routes 全局路由表
+var routes = router{
+	groups: make(map[int64]map[int64]struct{}),
+	muted:  make(map[[2]int64]time.Time),
+	known:  make(map[int64]struct{}),
+	sends:  make(map[int64][]time.Time),
+}
+
+// router 记录各机器人所在的群与禁言状态
+type router struct {
+	mu       sync.RWMutex
+	strategy RouteStrategy
+	groups   map[int64]map[int64]struct{} // gid -> self ids
+	muted    map[[2]int64]time.Time       // {gid, self id} -> 解除时间, 全员禁言时 self id 为 0
+	known    map[int64]struct{}           // 已获取群列表的机器人
+	smu      sync.Mutex
+	sends    map[int64][]time.Time // 最近一分钟经 SendToGroup 发送的时间
+}
+
+func (r *router) join(gid, selfID int64) {
+	s, ok := r.groups[gid]
+	if !ok {
+		s = make(map[int64]struct{}, 2)
+		r.groups[gid] = s
+	}
+	s[selfID] = struct{}{}
+}
+
+func (r *router) leave(gid, selfID int64) {
+	delete(r.groups[gid], selfID)
+	if len(r.groups[gid]) == 0 {
+		delete(r.groups, gid)
+	}
+	delete(r.muted, [2]int64{gid, selfID})
+}
+
+// refresh 以 get_group_list 的结果替换 selfID 所在的群
+//
+//	仍在列表中的群保留禁言状态, 只清除已不在列表中的群
+func (r *router) refresh(selfID int64, ctx Context) {
+	groups, err := ctx.API().GetGroupList()
+	if err != nil {
+		log.Warnf("[route] 获取账号 %d 的群列表失败: %v", selfID, err)
+		return
+	}
+	current := make(map[int64]struct{}, len(groups))
+	for _, g := range groups {
+		current[g.ID] = struct{}{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.known[selfID] = struct{}{}
+	for gid, bots := range r.groups {
+		if _, ok := current[gid]; !ok {
+			if _, ok := bots[selfID]; ok {
+				r.leave(gid, selfID)
+			}
+		}
+	}
+	for gid := range current {
+		r.join(gid, selfID)
+	}
+	log.Debugf("[route] 账号 %d 在 %d 个群中", selfID, len(groups))
+}
+
+// observe 根据事件更新路由表, 首次见到的机器人与重新连接时将异步获取群列表
+//
+//	仅在事件可能改变路由表时获取写锁
+func (r *router) observe(e *Event) {
+	if e.SelfID == 0 {
+		return
+	}
+	reconnect := e.PostType == "meta_event" && e.DetailType == "lifecycle" && e.SubType != "disable"
+	group := e.GroupID != 0 && e.DetailType != "guild"
+	r.mu.RLock()
+	_, known := r.known[e.SelfID]
+	_, joined := r.groups[e.GroupID][e.SelfID]
+	r.mu.RUnlock()
+	if known && !reconnect && (!group || !groupChange(e, joined)) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.known[e.SelfID]; !ok || reconnect {
+		r.known[e.SelfID] = struct{}{}
+		if ctx := GetBot(e.SelfID); ctx != nil {
+			go r.refresh(e.SelfID, ctx)
+		}
+	}
+	if !group {
+		return
+	}
+	switch {
+	case e.PostType == "message":
+		r.join(e.GroupID, e.SelfID)
+	case e.PostType != "notice":
+	case e.NoticeType == "group_increase" && e.UserID == e.SelfID:
+		r.join(e.GroupID, e.SelfID)
+	case e.NoticeType == "group_decrease" && (e.UserID == e.SelfID || e.SubType == "kick_me"):
+		r.leave(e.GroupID, e.SelfID)
+	case e.NoticeType == "group_ban" && (e.UserID == e.SelfID || e.UserID == 0):
+		key := [2]int64{e.GroupID, e.UserID}
+		if e.UserID == 0 { // 全员禁言对所有机器人生效
+			key[1] = 0
+		}
+		duration := e.RawEvent.Get("duration").Int()
+		switch {
+		case e.SubType == "lift_ban":
+			delete(r.muted, key)
+		case duration > 0:
+			r.muted[key] = time.Now().Add(time.Duration(duration) * time.Second)
+		default: // 全员禁言没有时长
+			r.muted[key] = time.Now().Add(100 * 365 * 24 * time.Hour)
+		}
+	}
+}
+
+// groupChange 群事件 e 是否会改变路由表, joined 为机器人是否已记录在该群
+func groupChange(e *Event, joined bool) bool {
+	switch {
+	case e.PostType == "message":
+		return !joined
+	case e.PostType != "notice":
+		return false
+	case e.NoticeType == "group_increase":
+		return e.UserID == e.SelfID
+	case e.NoticeType == "group_decrease":
+		return e.UserID == e.SelfID || e.SubType == "kick_me"
+	case e.NoticeType == "group_ban":
+		return e.UserID == e.SelfID || e.UserID == 0
+	}
+	return false
+}
+
+// candidates 按策略排序群 gid 内在线且未被禁言的机器人
+func (r *router) candidates(gid int64) []int64 {
+	now := time.Now()
+	r.mu.RLock()
+	strategy := r.strategy
+	wholeban := now.Before(r.muted[[2]int64{gid, 0}])
+	bots := make([]int64, 0, len(r.groups[gid]))
+	for id := range r.groups[gid] {
+		if wholeban || now.Before(r.muted[[2]int64{gid, id}]) {
+			continue
+		}
+		if _, ok := APICallers.Load(id); ok {
+			bots = append(bots, id)
+		}
+	}
+	r.mu.RUnlock()
+	if len(bots) == 0 {
+		return nil
+	}
+	sort.Slice(bots, func(i, j int) bool { return bots[i] < bots[j] })
+	if strategy == nil {
+		strategy = RoutePrimary()
+	}
+	return strategy(gid, bots)
+}
+
+// sent 记录一次发送, 用于 RouteLeastLoaded
+func (r *router) sent(selfID int64) {
+	r.smu.Lock()
+	r.sends[selfID] = append(r.trim(selfID), time.Now())
+	r.smu.Unlock()
+}
+
+// load 最近一分钟的发送次数
+func (r *router) load(selfID int64) int {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+	return len(r.trim(selfID))
+}
+
+func (r *router) trim(selfID int64) []time.Time {
+	s := r.sends[selfID]
+	deadline := time.Now().Add(-time.Minute)
+	i := sort.Search(len(s), func(i int) bool { return s[i].After(deadline) })
+	s = s[i:]
+	r.sends[selfID] = s
+	return s
+}
@@ -0,0 +1,61 @@
+package zero
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestSendToGroup(t *testing.T) {
+	routes.mu.Lock()
+	routes.groups = make(map[int64]map[int64]struct{})
+	routes.muted = make(map[[2]int64]time.Time)
+	routes.known = make(map[int64]struct{})
+	routes.mu.Unlock()
+	groups := APIResponse{Status: "ok", Data: gjson.Parse(`[{"group_id":1,"group_name":"a"}]`)}
+	APICallers.Store(30001, mockCaller{
+		"get_group_list": groups,
+		"send_group_msg": {Status: "failed", RetCode: 100, Msg: "BOT_MUTED"},
+	})
+	APICallers.Store(30002, mockCaller{
+		"get_group_list": groups,
+		"send_group_msg": {Status: "ok", Data: gjson.Parse(`{"message_id":2}`)},
+	})
+	defer APICallers.Delete(30001)
+	defer APICallers.Delete(30002)
+	RefreshGroups(30001) // 同步获取群列表并标记为已知, 之后的 observe 不会再异步刷新
+	RefreshGroups(30002)
+	assert.Equal(t, []int64{30001, 30002}, GroupBots(1))
+
+	mid, err := SendToGroup(1, "hello") // 30001 失败后由 30002 发送
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), mid.ID())
+
+	routes.observe(&Event{SelfID: 30002, PostType: "notice", NoticeType: "group_ban", SubType: "ban",
+		GroupID: 1, UserID: 30002, RawEvent: gjson.Parse(`{"duration":600}`)})
+	RefreshGroups(30002) // 刷新群列表保留禁言状态
+	_, err = SendToGroup(1, "hello")
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+
+	routes.observe(&Event{SelfID: 30001, PostType: "notice", NoticeType: "group_decrease", SubType: "kick_me",
+		GroupID: 1, UserID: 30001})
+	_, err = SendToGroup(1, "hello")
+	assert.ErrorIs(t, err, ErrNoRoute)
+
+	_, err = SendToGroup(2, "hello")
+	assert.ErrorIs(t, err, ErrNoRoute)
+}
+
+func TestRouteStrategy(t *testing.T) {
+	assert.Equal(t, []int64{3, 1, 2}, RoutePrimary(3)(0, []int64{1, 2, 3}))
+	rr := RouteRoundRobin()
+	assert.Equal(t, []int64{1, 2, 3}, rr(1, []int64{1, 2, 3}))
+	assert.Equal(t, []int64{2, 3, 1}, rr(1, []int64{1, 2, 3}))
+	assert.Equal(t, []int64{1, 2, 3}, rr(2, []int64{1, 2, 3}))
+	routes.sent(4)
+	assert.Equal(t, []int64{5, 4}, RouteLeastLoaded()(0, []int64{4, 5}))
+}
@@ -90,10 +90,11 @@ func CommandRule(commands ...string) Rule {
 		}
 		first := ctx.GetEvent().Message[0]
 		firstMessage := first.Data["text"]
-		if !strings.HasPrefix(firstMessage, BotConfig.CommandPrefix) {
+		prefix := GetConfig().CommandPrefix
+		if !strings.HasPrefix(firstMessage, prefix) {
 			return false
 		}
-		cmdMessage := firstMessage[len(BotConfig.CommandPrefix):]
+		cmdMessage := firstMessage[len(prefix):]
 		for _, command := range commands {
 			if strings.HasPrefix(cmdMessage, command) {
 				ctx.GetState()["command"] = command
@@ -218,7 +219,7 @@ func OnlyGuild(ctx Context) bool {
 }
 
 func issu(id int64) bool {
-	for _, su := range BotConfig.SuperUsers {
+	for _, su := range GetConfig().SuperUsers {
 		if su == id {
 			return true
 		}
@@ -263,7 +264,8 @@ func GroupHigherPermission(gettarget func(ctx Context) int64) Rule {
 		}
 		if SuperUserPermission(ctx) {
 			sender := ctx.GetEvent().UserID
-			return BotConfig.GetFirstSuperUser(sender, target) == sender
+			cfg := GetConfig()
+			return cfg.GetFirstSuperUser(sender, target) == sender
 		}
 		if ctx.GetEvent().Sender.Role == "owner" {
 			return !issu(target) && ctx.GetThisGroupMemberInfo(target, false).Get("role").Str != "owner"
@@ -304,7 +306,10 @@ func MustProvidePicture(ctx Context) bool {
 	select {
 	case <-time.After(time.Second * 120):
 		return false
-	case newctx := <-next:
+	case newctx, ok := <-next:
+		if !ok { // Shutdown
+			return false
+		}
 		ctx.GetState()["image_url"] = newctx.GetState()["image_url"]
 		ctx.GetEvent().MessageID = newctx.GetEvent().MessageID
 		return true
@@ -1,9 +1,18 @@
 package zero
 
 import (
+	"encoding"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
 )
 
 func isSpace(r rune) bool {
@@ -98,9 +107,23 @@ func ParseShell(s string) []string {
 }
 
 // ShellRule 定义shell-like规则
+//
+//	参数解析失败时规则不通过, 命令完全匹配时向用户回复错误与用法;
+//	需在权限等规则之后回复时, 将 ShellRule 置于这些规则之后, 或使用 OnShell
 func ShellRule(cmd string, model interface{}) Rule {
+	return shellRule(cmd, model, false)
+}
+
+// shellUsageKey 解析失败时 State 中待回复的错误与用法
+const shellUsageKey = "shell_usage"
+
+// shellRule 命令完全匹配但解析失败时回复错误与用法
+//
+//	deferred 时将用法存入 State 并通过, 由 replyShellUsage 在其余规则之后回复
+func shellRule(cmd string, model interface{}, deferred bool) Rule {
 	cmdRule := CommandRule(cmd)
 	t := reflect.TypeOf(model)
+	registerFlag(t, reflect.New(t)) // 注册时检查字段类型与标签
 	return func(ctx Context) bool {
 		if !cmdRule(ctx) {
 			return false
@@ -109,9 +132,20 @@ func ShellRule(cmd string, model interface{}) Rule {
 		args := ParseShell(ctx.GetState()["args"].(string))
 		val := reflect.New(t)
 		fs := registerFlag(t, val)
-		err := fs.Parse(args)
-		if err != nil {
-			return false
+		if err := fs.parse(args); err != nil {
+			if !exactCommand(ctx, cmd) {
+				return false
+			}
+			usage := fs.usage(GetConfig().CommandPrefix + cmd)
+			if err != flag.ErrHelp {
+				usage = err.Error() + "\n" + usage
+			}
+			if !deferred {
+				ctx.SendChain(message.Text(usage))
+				return false
+			}
+			ctx.GetState()[shellUsageKey] = usage
+			return true
 		}
 		ctx.GetState()["args"] = fs.Args()
 		ctx.GetState()["flag"] = val.Interface()
@@ -119,35 +153,354 @@ func ShellRule(cmd string, model interface{}) Rule {
 	}
 }
 
+// replyShellUsage 回复 shellRule 存入的用法, 此时规则不通过
+func replyShellUsage(ctx Context) bool {
+	usage, ok := ctx.GetState()[shellUsageKey].(string)
+	if !ok {
+		return true
+	}
+	ctx.SendChain(message.Text(usage))
+	return false
+}
+
+// exactCommand 首段文本的命令 cmd 之后为空白或结束
+func exactCommand(ctx Context, cmd string) bool {
+	msg := ctx.GetEvent().Message
+	if len(msg) == 0 || msg[0].Type != "text" {
+		return false
+	}
+	text, full := msg[0].Data["text"], GetConfig().CommandPrefix+cmd
+	if !strings.HasPrefix(text, full) {
+		return false
+	}
+	rest := text[len(full):]
+	return rest == "" || isSpace(rune(rest[0]))
+}
+
 var (
 	boolType    = reflect.TypeOf(false)
 	intType     = reflect.TypeOf(0)
+	int64Type   = reflect.TypeOf(int64(0))
+	uintType    = reflect.TypeOf(uint(0))
 	stringType  = reflect.TypeOf("")
 	float64Type = reflect.TypeOf(float64(0))
+	stringsType = reflect.TypeOf([]string(nil))
+	int64sType  = reflect.TypeOf([]int64(nil))
 )
 
-func registerFlag(t reflect.Type, v reflect.Value) *flag.FlagSet {
+// flagSet 绑定到结构体的 flag.FlagSet
+//
+//	pos 为 pos 标签绑定的位置参数, 按序号注册在其中, 切片字段接收其后的所有位置参数
+type flagSet struct {
+	*flag.FlagSet
+	pos      *flag.FlagSet
+	posNames []string
+	required []requiredFlag
+	shorts   map[string]string   // 名称 -> 短别名
+	aliases  map[string]struct{} // 短别名
+	defaults map[string]struct{} // 有 default 标签的 flag
+}
+
+type requiredFlag struct {
+	name, short string
+	pos         int // 位置参数序号, 非位置参数为 -1
+}
+
+// registerFlag 按字段标签注册 flag
+//
+//	flag: 名称; short: 短别名; help: 说明; default: 默认值, 切片以 , 分隔;
+//	required:"true" 必须提供; pos: 绑定到第 n 个位置参数, 从 0 开始
+func registerFlag(t reflect.Type, v reflect.Value) *flagSet {
 	v = v.Elem()
-	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs := &flagSet{
+		FlagSet:  flag.NewFlagSet("", flag.ContinueOnError),
+		pos:      flag.NewFlagSet("", flag.ContinueOnError),
+		shorts:   make(map[string]string),
+		aliases:  make(map[string]struct{}),
+		defaults: make(map[string]struct{}),
+	}
+	fs.SetOutput(io.Discard) // 错误与用法由 parse 与 usage 返回
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
 		name := field.Tag.Get("flag")
-		if name == "" {
+		pos, hasPos := field.Tag.Lookup("pos")
+		if name == "" && !hasPos {
 			continue
 		}
 		help := field.Tag.Get("help")
-		switch field.Type {
-		case boolType:
-			fs.BoolVar(v.Field(i).Addr().Interface().(*bool), name, false, help)
-		case intType:
-			fs.IntVar(v.Field(i).Addr().Interface().(*int), name, 0, help)
-		case stringType:
-			fs.StringVar(v.Field(i).Addr().Interface().(*string), name, "", help)
-		case float64Type:
-			fs.Float64Var(v.Field(i).Addr().Interface().(*float64), name, 0, help)
-		default:
-			panic("unsupported type")
+		req := requiredFlag{name: name, pos: -1}
+		if name != "" {
+			bindFlag(fs.FlagSet, v.Field(i), field, name, help)
+			if _, ok := field.Tag.Lookup("default"); ok {
+				fs.defaults[name] = struct{}{}
+			}
+			if short := field.Tag.Get("short"); short != "" {
+				f := fs.Lookup(name)
+				fs.Var(f.Value, short, help)
+				fs.Lookup(short).DefValue = f.DefValue
+				fs.shorts[name] = short
+				fs.aliases[short] = struct{}{}
+				req.short = short
+			}
+		}
+		if hasPos {
+			n, err := strconv.Atoi(pos)
+			if err != nil || n < 0 {
+				panic("invalid pos tag of field " + field.Name)
+			}
+			if name != "" { // 与 flag 共用同一 Value, 默认值只设置一次
+				f := fs.Lookup(name)
+				fs.pos.Var(f.Value, pos, help)
+				fs.pos.Lookup(pos).DefValue = f.DefValue
+			} else {
+				bindFlag(fs.pos, v.Field(i), field, pos, help)
+			}
+			for len(fs.posNames) <= n {
+				fs.posNames = append(fs.posNames, "")
+			}
+			fs.posNames[n] = strings.ToLower(field.Name)
+			if name == "" {
+				req.name, req.pos = fs.posNames[n], n
+			}
+		}
+		if field.Tag.Get("required") == "true" {
+			fs.required = append(fs.required, req)
 		}
 	}
 	return fs
 }
+
+// bindFlag 将字段 v 注册为 fs 中名为 name 的 flag, 并设置 default 标签的默认值
+func bindFlag(fs *flag.FlagSet, v reflect.Value, field reflect.StructField, name, help string) {
+	if fs.Lookup(name) != nil {
+		return // 已由同一字段注册
+	}
+	p := v.Addr().Interface()
+	switch field.Type {
+	case boolType:
+		fs.BoolVar(p.(*bool), name, false, help)
+	case intType:
+		fs.IntVar(p.(*int), name, 0, help)
+	case int64Type:
+		fs.Int64Var(p.(*int64), name, 0, help)
+	case uintType:
+		fs.UintVar(p.(*uint), name, 0, help)
+	case stringType:
+		fs.StringVar(p.(*string), name, "", help)
+	case float64Type:
+		fs.Float64Var(p.(*float64), name, 0, help)
+	case durationType:
+		fs.DurationVar(p.(*time.Duration), name, 0, help)
+	case stringsType:
+		fs.Var(&stringsValue{p: p.(*[]string)}, name, help)
+	case int64sType:
+		fs.Var(&int64sValue{p: p.(*[]int64)}, name, help)
+	default:
+		u, ok := p.(encoding.TextUnmarshaler)
+		if !ok {
+			panic("unsupported type")
+		}
+		fs.Var(textValue{u}, name, help)
+	}
+	def, ok := field.Tag.Lookup("default")
+	if !ok {
+		return
+	}
+	f := fs.Lookup(name)
+	values := []string{def}
+	if field.Type == stringsType || field.Type == int64sType {
+		values = strings.Split(def, ",")
+	}
+	for _, d := range values {
+		if err := f.Value.Set(d); err != nil {
+			panic("invalid default of field " + field.Name + ": " + err.Error())
+		}
+	}
+	if s, ok := f.Value.(interface{ setDefault() }); ok {
+		s.setDefault()
+	}
+	f.DefValue = def
+}
+
+// parse 解析 flag 与位置参数并检查 required, -h 时返回 flag.ErrHelp
+func (fs *flagSet) parse(args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return fs.flagError(err)
+	}
+	if err := fs.bindPositionals(); err != nil {
+		return err
+	}
+	return fs.checkRequired()
+}
+
+// flagErrorRe flag 包的参数值错误, 如 invalid value "x" for flag -n: parse error
+var flagErrorRe = regexp.MustCompile(`^invalid (?:boolean )?value (".*") for (?:flag )?-(\S+): (.*)$`)
+
+// flagError 将 flag 包的错误转换为中文
+func (fs *flagSet) flagError(err error) error {
+	msg := err.Error()
+	switch {
+	case err == flag.ErrHelp:
+		return err
+	case strings.HasPrefix(msg, "flag provided but not defined: "):
+		return errors.New("未知选项 " + strings.TrimPrefix(msg, "flag provided but not defined: "))
+	case strings.HasPrefix(msg, "flag needs an argument: "):
+		return errors.New("选项 " + strings.TrimPrefix(msg, "flag needs an argument: ") + " 缺少值")
+	case strings.HasPrefix(msg, "bad flag syntax: "):
+		return errors.New("选项格式错误: " + strings.TrimPrefix(msg, "bad flag syntax: "))
+	}
+	m := flagErrorRe.FindStringSubmatch(msg)
+	if m == nil {
+		return err
+	}
+	msg = "选项 -" + m[2] + " 的值 " + m[1] + " 无效"
+	if f := fs.Lookup(m[2]); f != nil {
+		if _, ok := f.Value.(textValue); ok { // TextUnmarshaler 的错误说明原因
+			msg += ": " + m[3]
+		}
+	}
+	return errors.New(msg)
+}
+
+func (fs *flagSet) bindPositionals() error {
+	args := fs.Args()
+	for n := range fs.posNames {
+		f := fs.pos.Lookup(strconv.Itoa(n))
+		if f == nil || n >= len(args) {
+			continue
+		}
+		rest := args[n : n+1]
+		if _, ok := f.Value.(sliceValue); ok {
+			rest = args[n:]
+		}
+		for _, a := range rest {
+			if err := fs.pos.Set(f.Name, a); err != nil {
+				return fmt.Errorf("参数 %s 的值 %q 无效", fs.posNames[n], a)
+			}
+		}
+	}
+	return nil
+}
+
+func (fs *flagSet) checkRequired() error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, r := range fs.required {
+		switch {
+		case r.pos >= 0:
+			if r.pos >= len(fs.Args()) {
+				return errors.New("缺少参数 " + r.name)
+			}
+		case !set[r.name] && !set[r.short]:
+			return errors.New("缺少必需的选项 -" + r.name)
+		}
+	}
+	return nil
+}
+
+// usage 生成用法, cmd 含 CommandPrefix
+func (fs *flagSet) usage(cmd string) string {
+	sb := strings.Builder{}
+	sb.WriteString("用法: " + cmd + " [选项]")
+	for _, name := range fs.posNames {
+		if name != "" {
+			sb.WriteString(" <" + name + ">")
+		}
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := fs.aliases[f.Name]; ok {
+			return
+		}
+		sb.WriteString("\n  -" + f.Name)
+		if short, ok := fs.shorts[f.Name]; ok {
+			sb.WriteString(", -" + short)
+		}
+		typ, help := flag.UnquoteUsage(f)
+		if typ != "" {
+			sb.WriteString(" " + typ)
+		}
+		if help != "" {
+			sb.WriteString("  " + help)
+		}
+		if _, ok := fs.defaults[f.Name]; ok {
+			sb.WriteString(" (默认 " + f.DefValue + ")")
+		}
+	})
+	return sb.String()
+}
+
+// sliceValue 可重复的 flag, 默认值在首次设置时被替换
+type sliceValue interface {
+	flag.Value
+	setDefault()
+}
+
+type stringsValue struct {
+	p   *[]string
+	def bool
+}
+
+func (s *stringsValue) Set(v string) error {
+	if s.def {
+		*s.p, s.def = nil, false
+	}
+	*s.p = append(*s.p, v)
+	return nil
+}
+
+func (s *stringsValue) String() string {
+	if s.p == nil {
+		return ""
+	}
+	return strings.Join(*s.p, ",")
+}
+
+func (s *stringsValue) setDefault() { s.def = true }
+
+type int64sValue struct {
+	p   *[]int64
+	def bool
+}
+
+func (s *int64sValue) Set(v string) error {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return err
+	}
+	if s.def {
+		*s.p, s.def = nil, false
+	}
+	*s.p = append(*s.p, n)
+	return nil
+}
+
+func (s *int64sValue) String() string {
+	if s.p == nil {
+		return ""
+	}
+	ss := make([]string, len(*s.p))
+	for i, n := range *s.p {
+		ss[i] = strconv.FormatInt(n, 10)
+	}
+	return strings.Join(ss, ",")
+}
+
+func (s *int64sValue) setDefault() { s.def = true }
+
+type textValue struct {
+	u encoding.TextUnmarshaler
+}
+
+func (t textValue) Set(v string) error {
+	return t.u.UnmarshalText([]byte(v))
+}
+
+func (t textValue) String() string {
+	if m, ok := t.u.(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err == nil {
+			return string(b)
+		}
+	}
+	return ""
+}
@@ -1,11 +1,17 @@
 package zero
 
 import (
+	"errors"
+	"flag"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
 )
 
 func Test_parse(t *testing.T) {
@@ -43,3 +49,110 @@ func Test_registerFlag(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expected, got)
 }
+
+type level int
+
+func (l *level) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+func Test_registerFlagTypes(t *testing.T) {
+	type args struct {
+		UID     int64         `flag:"uid" short:"u" required:"true"`
+		N       uint          `flag:"n" default:"3"`
+		Wait    time.Duration `flag:"wait" default:"1m"`
+		Tags    []string      `flag:"tag" default:"a,b"`
+		Groups  []int64       `flag:"g"`
+		Level   level         `flag:"level"`
+		Target  string        `pos:"0" required:"true"`
+		Rest    []string      `pos:"1"`
+		Ignored string
+	}
+	var got args
+	fs := registerFlag(reflect.TypeOf(got), reflect.ValueOf(&got))
+	err := fs.parse([]string{"-u", "123", "-tag", "x", "-g", "1", "-g=2", "-level", "high", "t", "r1", "r2"})
+	assert.NoError(t, err)
+	assert.Equal(t, args{
+		UID: 123, N: 3, Wait: time.Minute, Tags: []string{"x"}, Groups: []int64{1, 2},
+		Level: 2, Target: "t", Rest: []string{"r1", "r2"},
+	}, got)
+
+	got = args{}
+	fs = registerFlag(reflect.TypeOf(got), reflect.ValueOf(&got))
+	assert.NoError(t, fs.parse([]string{"-uid=1", "t"}))
+	assert.Equal(t, []string{"a", "b"}, got.Tags)
+	assert.Nil(t, got.Rest)
+
+	for _, c := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"t"}, "缺少必需的选项 -uid"},
+		{[]string{"-u", "1"}, "缺少参数 target"},
+		{[]string{"-u", "x", "t"}, `选项 -u 的值 "x" 无效`},
+		{[]string{"-u", "1", "-level", "mid", "t"}, `选项 -level 的值 "mid" 无效: unknown level`},
+		{[]string{"-x", "t"}, "未知选项 -x"},
+		{[]string{"t", "-u"}, "缺少必需的选项 -uid"},
+		{[]string{"-u"}, "选项 -u 缺少值"},
+	} {
+		got = args{}
+		fs = registerFlag(reflect.TypeOf(got), reflect.ValueOf(&got))
+		assert.EqualError(t, fs.parse(c.args), c.err)
+	}
+	assert.ErrorIs(t, fs.parse([]string{"-h"}), flag.ErrHelp)
+	usage := fs.usage("/cmd")
+	assert.True(t, strings.HasPrefix(usage, "用法: /cmd [选项] <target>"))
+	assert.Contains(t, usage, "-uid, -u int")
+	assert.Contains(t, usage, "(默认 1m)")
+	assert.NotContains(t, usage, "\n  -u ")
+
+	assert.PanicsWithValue(t, "unsupported type", func() {
+		var v struct {
+			M map[string]string `flag:"m"`
+		}
+		registerFlag(reflect.TypeOf(v), reflect.ValueOf(&v))
+	})
+}
+
+func TestExactCommand(t *testing.T) {
+	prefix := GetConfig().CommandPrefix
+	for text, want := range map[string]bool{
+		prefix + "ban":         true,
+		prefix + "ban -u 1":    true,
+		prefix + "ban\n-u 1":   true,
+		prefix + "banana":      false,
+		prefix + "bans -u 1":   false,
+		"ban" + prefix + "ban": false,
+	} {
+		ctx := &Ctx{Event: &Event{Message: message.Message{message.Text(text)}}}
+		assert.Equal(t, want, exactCommand(ctx, "ban"), text)
+	}
+}
+
+func Test_registerFlagPos(t *testing.T) {
+	type args struct {
+		Tags []string `flag:"tag" pos:"0" default:"a,b"`
+	}
+	for _, c := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"a", "b"}},
+		{[]string{"x", "y"}, []string{"x", "y"}}, // 位置参数替换默认值
+		{[]string{"-tag", "x"}, []string{"x"}},
+		{[]string{"-tag", "x", "y"}, []string{"x", "y"}},
+	} {
+		var got args
+		fs := registerFlag(reflect.TypeOf(got), reflect.ValueOf(&got))
+		assert.NoError(t, fs.parse(c.args))
+		assert.Equal(t, c.want, got.Tags, c.args)
+	}
+}
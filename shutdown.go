@@ -0,0 +1,104 @@
+package zero
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrShutdown 已调用过 Shutdown
+var ErrShutdown = errors.New("zero: bot is shutting down")
+
+var (
+	// inflight 处理中的事件与 FutureEvent
+	inflight sync.WaitGroup
+	// shutdownmu 保证 Shutdown 后不再增加 inflight
+	shutdownmu sync.RWMutex
+	isshutdown bool
+	// shutdownch Shutdown 时关闭
+	shutdownch = make(chan struct{})
+	// basectx 所有事件 context 的父 context, 等待超时后取消
+	basectx, cancelbase = context.WithCancel(context.Background())
+
+	shutdownhooks   []func() error
+	shutdownhooksmu sync.Mutex
+)
+
+// track 在未关闭时记录一个处理中的任务, 返回 false 表示已关闭
+func track() bool {
+	shutdownmu.RLock()
+	defer shutdownmu.RUnlock()
+	if isshutdown {
+		return false
+	}
+	inflight.Add(1)
+	return true
+}
+
+// OnShutdown 注册在 Shutdown 最后执行的函数, 用于关闭数据库等
+//
+//	按注册的相反顺序执行
+func OnShutdown(f func() error) {
+	shutdownhooksmu.Lock()
+	shutdownhooks = append(shutdownhooks, f)
+	shutdownhooksmu.Unlock()
+}
+
+// Shutdown 停止接收事件, 等待处理中的事件与 FutureEvent,
+// 关闭所有 Driver 并执行 OnShutdown 注册的函数
+//
+//	ctx 结束后不再等待, 并取消所有未完成的 API 调用
+func Shutdown(ctx context.Context) error {
+	shutdownmu.Lock()
+	if isshutdown {
+		shutdownmu.Unlock()
+		return ErrShutdown
+	}
+	isshutdown = true
+	shutdownmu.Unlock()
+
+	log.Infoln("[bot] 开始关闭, 已停止接收事件")
+	evring.stop()
+	close(shutdownch)
+	deleteFutureMatchers()
+
+	var err error
+	done := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Warnln("[bot] 等待事件处理完成超时, 取消未完成的 API 调用")
+	}
+	cancelbase()
+
+	for _, d := range GetConfig().Driver {
+		if e := d.Close(); e != nil {
+			log.Warnln("[bot] 关闭 Driver 时出现错误:", e)
+			if err == nil {
+				err = e
+			}
+		}
+	}
+
+	shutdownhooksmu.Lock()
+	hooks := shutdownhooks
+	shutdownhooks = nil
+	shutdownhooksmu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if e := hooks[i](); e != nil {
+			log.Warnln("[bot] 执行关闭函数时出现错误:", e)
+			if err == nil {
+				err = e
+			}
+		}
+	}
+	log.Infoln("[bot] 已关闭")
+	return err
+}
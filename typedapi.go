@@ -0,0 +1,321 @@
+package zero
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// APIError API 调用返回值非 0 时的错误, 可通过 errors.As 获取
+type APIError struct {
+	Action  string
+	RetCode int64
+	Msg     string
+	Wording string
+}
+
+// Error 实现 error
+func (e *APIError) Error() string {
+	s := "call " + e.Action + " failed, retcode: " + strconv.FormatInt(e.RetCode, 10)
+	if e.Msg != "" {
+		s += ", msg: " + e.Msg
+	}
+	if e.Wording != "" {
+		s += ", wording: " + e.Wording
+	}
+	return s
+}
+
+// LoginInfo 登录号信息
+type LoginInfo struct {
+	UserID   int64  `json:"user_id"`
+	NickName string `json:"nickname"`
+}
+
+// FriendInfo 好友信息
+type FriendInfo struct {
+	UserID   int64  `json:"user_id"`
+	NickName string `json:"nickname"`
+	Remark   string `json:"remark"`
+}
+
+// GroupMember 群成员信息
+type GroupMember struct {
+	GroupID         int64  `json:"group_id"`
+	UserID          int64  `json:"user_id"`
+	NickName        string `json:"nickname"`
+	Card            string `json:"card"`
+	Sex             string `json:"sex"` // "male"、"female"、"unknown"
+	Age             int    `json:"age"`
+	Area            string `json:"area"`
+	JoinTime        int64  `json:"join_time"`
+	LastSentTime    int64  `json:"last_sent_time"`
+	Level           string `json:"level"`
+	Role            string `json:"role"` // "owner"、"admin"、"member"
+	Unfriendly      bool   `json:"unfriendly"`
+	Title           string `json:"title"`
+	TitleExpireTime int64  `json:"title_expire_time"`
+	CardChangeable  bool   `json:"card_changeable"`
+	ShutUpTimestamp int64  `json:"shut_up_timestamp"`
+}
+
+// Name 群名片, 为空时返回昵称
+func (m *GroupMember) Name() string {
+	if m.Card != "" {
+		return m.Card
+	}
+	return m.NickName
+}
+
+// VersionInfo 版本信息
+type VersionInfo struct {
+	AppName         string `json:"app_name"`
+	AppVersion      string `json:"app_version"`
+	ProtocolVersion string `json:"protocol_version"`
+}
+
+// HonorMember 群荣誉成员
+type HonorMember struct {
+	UserID      int64  `json:"user_id"`
+	NickName    string `json:"nickname"`
+	Avatar      string `json:"avatar"`
+	Description string `json:"description"`
+	DayCount    int    `json:"day_count"` // 仅 current_talkative
+}
+
+// HonorInfo 群荣誉信息
+type HonorInfo struct {
+	GroupID          int64         `json:"group_id"`
+	CurrentTalkative *HonorMember  `json:"current_talkative"`
+	TalkativeList    []HonorMember `json:"talkative_list"`
+	PerformerList    []HonorMember `json:"performer_list"`
+	LegendList       []HonorMember `json:"legend_list"`
+	StrongNewbieList []HonorMember `json:"strong_newbie_list"`
+	EmotionList      []HonorMember `json:"emotion_list"`
+}
+
+// API 返回结构体与 error 的 API, 通过 ctx.API() 获取
+//
+//	调用失败时返回 API 调用错误或 *APIError
+type API struct {
+	ctx *Ctx
+}
+
+// API 获取返回结构体与 error 的 API
+func (ctx *Ctx) API() API {
+	return API{ctx: ctx}
+}
+
+// Call 调用 action 并将返回的 data 解析到 v, v 为 nil 时不解析
+func (api API) Call(action string, params Params, v interface{}) error {
+	rsp, err := api.ctx.callAction(action, params)
+	if err != nil {
+		return err
+	}
+	return decodeData(action, rsp.Data, v)
+}
+
+func decodeData(action string, data gjson.Result, v interface{}) error {
+	if v == nil || data.Raw == "" || data.Type == gjson.Null {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(data.Raw), v); err != nil {
+		return fmt.Errorf("decode %s response: %w", action, err)
+	}
+	return nil
+}
+
+// SendGroupMessage 发送群消息
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#send_group_msg-%E5%8F%91%E9%80%81%E7%BE%A4%E6%B6%88%E6%81%AF
+func (api API) SendGroupMessage(groupID int64, message interface{}) (message.MessageID, error) {
+	return api.send("send_group_msg", Params{
+		"group_id": groupID,
+		"message":  message,
+	})
+}
+
+// SendPrivateMessage 发送私聊消息
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#send_private_msg-%E5%8F%91%E9%80%81%E7%A7%81%E8%81%8A%E6%B6%88%E6%81%AF
+func (api API) SendPrivateMessage(userID int64, message interface{}) (message.MessageID, error) {
+	return api.send("send_private_msg", Params{
+		"user_id": userID,
+		"message": message,
+	})
+}
+
+func (api API) send(action string, params Params) (message.MessageID, error) {
+	rsp, err := api.ctx.callAction(action, params)
+	if err != nil {
+		return message.MessageID{}, err
+	}
+	id := rsp.Data.Get("message_id")
+	if !id.Exists() {
+		return message.MessageID{}, fmt.Errorf("%s: no message_id in response", action)
+	}
+	if id.Type == gjson.Number {
+		return message.NewMessageIDFromInteger(id.Int()), nil
+	}
+	return message.NewMessageIDFromString(id.String()), nil
+}
+
+// DeleteMessage 撤回消息
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#delete_msg-%E6%92%A4%E5%9B%9E%E6%B6%88%E6%81%AF
+func (api API) DeleteMessage(messageID interface{}) error {
+	return api.Call("delete_msg", Params{
+		"message_id": messageID,
+	}, nil)
+}
+
+// SetGroupKick 群组踢人
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#set_group_kick-%E7%BE%A4%E7%BB%84%E8%B8%A2%E4%BA%BA
+func (api API) SetGroupKick(groupID, userID int64, rejectAddRequest bool) error {
+	return api.Call("set_group_kick", Params{
+		"group_id":           groupID,
+		"user_id":            userID,
+		"reject_add_request": rejectAddRequest,
+	}, nil)
+}
+
+// SetGroupBan 群组单人禁言
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#set_group_ban-%E7%BE%A4%E7%BB%84%E5%8D%95%E4%BA%BA%E7%A6%81%E8%A8%80
+func (api API) SetGroupBan(groupID, userID, duration int64) error {
+	return api.Call("set_group_ban", Params{
+		"group_id": groupID,
+		"user_id":  userID,
+		"duration": duration,
+	}, nil)
+}
+
+// SetGroupWholeBan 群组全员禁言
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#set_group_whole_ban-%E7%BE%A4%E7%BB%84%E5%85%A8%E5%91%98%E7%A6%81%E8%A8%80
+func (api API) SetGroupWholeBan(groupID int64, enable bool) error {
+	return api.Call("set_group_whole_ban", Params{
+		"group_id": groupID,
+		"enable":   enable,
+	}, nil)
+}
+
+// SetGroupCard 设置群名片（群备注）
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#set_group_card-%E8%AE%BE%E7%BD%AE%E7%BE%A4%E5%90%8D%E7%89%87%E7%BE%A4%E5%A4%87%E6%B3%A8
+func (api API) SetGroupCard(groupID, userID int64, card string) error {
+	return api.Call("set_group_card", Params{
+		"group_id": groupID,
+		"user_id":  userID,
+		"card":     card,
+	}, nil)
+}
+
+// SetGroupLeave 退出群组
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#set_group_leave-%E9%80%80%E5%87%BA%E7%BE%A4%E7%BB%84
+func (api API) SetGroupLeave(groupID int64, isDismiss bool) error {
+	return api.Call("set_group_leave", Params{
+		"group_id":   groupID,
+		"is_dismiss": isDismiss,
+	}, nil)
+}
+
+// SetFriendAddRequest 处理加好友请求
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#set_friend_add_request-%E5%A4%84%E7%90%86%E5%8A%A0%E5%A5%BD%E5%8F%8B%E8%AF%B7%E6%B1%82
+func (api API) SetFriendAddRequest(flag string, approve bool, remark string) error {
+	return api.Call("set_friend_add_request", Params{
+		"flag":    flag,
+		"approve": approve,
+		"remark":  remark,
+	}, nil)
+}
+
+// SetGroupAddRequest 处理加群请求／邀请
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#set_group_add_request-%E5%A4%84%E7%90%86%E5%8A%A0%E7%BE%A4%E8%AF%B7%E6%B1%82%E9%82%80%E8%AF%B7
+func (api API) SetGroupAddRequest(flag string, subType string, approve bool, reason string) error {
+	return api.Call("set_group_add_request", Params{
+		"flag":     flag,
+		"sub_type": subType,
+		"approve":  approve,
+		"reason":   reason,
+	}, nil)
+}
+
+// GetLoginInfo 获取登录号信息
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#get_login_info-%E8%8E%B7%E5%8F%96%E7%99%BB%E5%BD%95%E5%8F%B7%E4%BF%A1%E6%81%AF
+func (api API) GetLoginInfo() (info LoginInfo, err error) {
+	err = api.Call("get_login_info", Params{}, &info)
+	return
+}
+
+// GetStrangerInfo 获取陌生人信息
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#get_stranger_info-%E8%8E%B7%E5%8F%96%E9%99%8C%E7%94%9F%E4%BA%BA%E4%BF%A1%E6%81%AF
+func (api API) GetStrangerInfo(userID int64, noCache bool) (user User, err error) {
+	err = api.Call("get_stranger_info", Params{
+		"user_id":  userID,
+		"no_cache": noCache,
+	}, &user)
+	return
+}
+
+// GetFriendList 获取好友列表
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#get_friend_list-%E8%8E%B7%E5%8F%96%E5%A5%BD%E5%8F%8B%E5%88%97%E8%A1%A8
+func (api API) GetFriendList() (friends []FriendInfo, err error) {
+	err = api.Call("get_friend_list", Params{}, &friends)
+	return
+}
+
+// GetGroupInfo 获取群信息
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#get_group_info-%E8%8E%B7%E5%8F%96%E7%BE%A4%E4%BF%A1%E6%81%AF
+func (api API) GetGroupInfo(groupID int64, noCache bool) (group Group, err error) {
+	err = api.Call("get_group_info", Params{
+		"group_id": groupID,
+		"no_cache": noCache,
+	}, &group)
+	return
+}
+
+// GetGroupList 获取群列表
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#get_group_list-%E8%8E%B7%E5%8F%96%E7%BE%A4%E5%88%97%E8%A1%A8
+func (api API) GetGroupList() (groups []Group, err error) {
+	err = api.Call("get_group_list", Params{}, &groups)
+	return
+}
+
+// GetGroupMemberInfo 获取群成员信息
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#get_group_member_info-%E8%8E%B7%E5%8F%96%E7%BE%A4%E6%88%90%E5%91%98%E4%BF%A1%E6%81%AF
+func (api API) GetGroupMemberInfo(groupID, userID int64, noCache bool) (member GroupMember, err error) {
+	err = api.Call("get_group_member_info", Params{
+		"group_id": groupID,
+		"user_id":  userID,
+		"no_cache": noCache,
+	}, &member)
+	return
+}
+
+// GetGroupMemberList 获取群成员列表
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#get_group_member_list-%E8%8E%B7%E5%8F%96%E7%BE%A4%E6%88%90%E5%91%98%E5%88%97%E8%A1%A8
+func (api API) GetGroupMemberList(groupID int64, noCache bool) (members []GroupMember, err error) {
+	err = api.Call("get_group_member_list", Params{
+		"group_id": groupID,
+		"no_cache": noCache,
+	}, &members)
+	return
+}
+
+// GetGroupHonorInfo 获取群荣誉信息
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#get_group_honor_info-%E8%8E%B7%E5%8F%96%E7%BE%A4%E8%8D%A3%E8%AA%89%E4%BF%A1%E6%81%AF
+//
+//	hType: talkative performer legend strong_newbie emotion all
+func (api API) GetGroupHonorInfo(groupID int64, hType string) (info HonorInfo, err error) {
+	err = api.Call("get_group_honor_info", Params{
+		"group_id": groupID,
+		"type":     hType,
+	}, &info)
+	return
+}
+
+// GetVersionInfo 获取版本信息
+// https://github.com/botuniverse/onebot-11/blob/master/api/public.md#get_version_info-%E8%8E%B7%E5%8F%96%E7%89%88%E6%9C%AC%E4%BF%A1%E6%81%AF
+func (api API) GetVersionInfo() (info VersionInfo, err error) {
+	err = api.Call("get_version_info", Params{}, &info)
+	return
+}
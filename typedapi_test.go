@@ -0,0 +1,45 @@
+package zero
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+type mockCaller map[string]APIResponse
+
+func (m mockCaller) CallApi(req APIRequest) (APIResponse, error) {
+	return m[req.Action], nil
+}
+
+func (m mockCaller) CallApiContext(_ context.Context, req APIRequest) (APIResponse, error) {
+	return m.CallApi(req)
+}
+
+func TestTypedAPI(t *testing.T) {
+	ctx := &Ctx{caller: mockCaller{
+		"get_group_member_info": {Status: "ok", Data: gjson.Parse(`{"group_id":1,"user_id":2,"nickname":"nick","card":"","role":"admin"}`)},
+		"set_group_ban":         {Status: "failed", RetCode: 100, Msg: "GROUP_NOT_FOUND", Wording: "群聊不存在"},
+		"send_group_msg":        {Status: "ok", Data: gjson.Parse(`{"message_id":123}`)},
+	}}
+	api := ctx.API()
+
+	member, err := api.GetGroupMemberInfo(1, 2, false)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), member.UserID)
+	assert.Equal(t, "admin", member.Role)
+	assert.Equal(t, "nick", member.Name())
+
+	err = api.SetGroupBan(1, 2, 60)
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, int64(100), apiErr.RetCode)
+	assert.Equal(t, "GROUP_NOT_FOUND", apiErr.Msg)
+
+	id, err := api.SendGroupMessage(1, "hi")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(123), id.ID())
+}
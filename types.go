@@ -30,7 +30,15 @@ type APIResponse struct {
 type APIRequest struct {
 	Action string `json:"action"`
 	Params Params `json:"params"`
-	Echo   uint64 `json:"echo"` // 该项不用填写，由Driver生成
+	Echo   uint64 `json:"echo"`           // 该项不用填写，由Driver生成
+	Self   *Self  `json:"self,omitempty"` // 该项不用填写，OneBot 12 下由Driver生成
+}
+
+// Self is the bot itself in OneBot 12
+// https://12.onebot.dev/connect/data-protocol/basic-types/#_10
+type Self struct {
+	Platform string `json:"platform"`
+	UserID   string `json:"user_id"`
 }
 
 // User is a user on QQ.
@@ -80,6 +88,7 @@ type Event struct {
 	OperatorID    int64           `json:"operator_id"` // This field is used for Notice Event
 	File          *File           `json:"file"`
 	RequestType   string          `json:"request_type"`
+	MetaEventType string          `json:"meta_event_type"`
 	Flag          string          `json:"flag"`
 	Comment       string          `json:"comment"` // This field is used for Request Event
 	Message       message.Message `json:"-"`       // Message parsed
@@ -87,6 +96,7 @@ type Event struct {
 	NativeMessage json.RawMessage `json:"message"`
 	IsToMe        bool            `json:"-"`
 	RawEvent      gjson.Result    `json:"-"` // raw event
+	Self          *Self           `json:"-"` // OneBot 12 事件的机器人自身标识, OneBot 11 下为 nil
 }
 
 // Message 消息
@@ -0,0 +1,191 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+// ErrConflict CheckConflicts 在严格模式下发现了冲突
+var ErrConflict = errors.New("control: conflicting matchers")
+
+// 冲突类型, 见 Conflict.Kind
+const (
+	ConflictDuplicate = "duplicate" // 触发字面量完全相同
+	ConflictPrefix    = "prefix"    // 一方的字面量是另一方的前缀
+	ConflictRegex     = "regex"     // 正则匹配另一方的字面量
+)
+
+// Conflict 不同插件的两个 Matcher 可能被同一条消息触发
+//
+//	A 为按匹配顺序靠前的一方, 其 Block 为 true 时 B 将被遮蔽
+type Conflict struct {
+	Kind         string
+	Literal      string // 两者均能匹配的字面量, 命令含 CommandPrefix
+	A, B         zero.MatcherInfo
+	SamePriority bool // 优先级相同, 先后取决于注册顺序
+}
+
+// String 用于日志
+func (c Conflict) String() string {
+	s := fmt.Sprintf("%s: %s 与 %s 均匹配 %q", c.Kind, describe(c.A), describe(c.B), c.Literal)
+	switch {
+	case c.SamePriority:
+		s += ", 优先级相同 (" + strconv.Itoa(c.A.Priority) + "), 先后取决于注册顺序"
+	case c.A.Block:
+		s += ", 后者将被阻断"
+	}
+	return s
+}
+
+// describe 插件名, 触发方式与位置
+func describe(m zero.MatcherInfo) string {
+	name := m.Service
+	switch {
+	case name == "":
+		name = "(未命名)"
+	case enmap[name] == nil:
+		name += "(非插件)"
+	}
+	return fmt.Sprintf("[%s] %s@%s:%d", name, m.Kind, filepath.Base(m.File), m.Line)
+}
+
+// pattern Matcher 在首段文本上的触发字面量
+type pattern struct {
+	text  string
+	exact bool // 完全匹配, 否则为前缀匹配
+}
+
+func patterns(m zero.MatcherInfo, prefix string) []pattern {
+	ps := make([]pattern, 0, len(m.Literals))
+	for _, l := range m.Literals {
+		switch m.Kind {
+		case zero.TriggerCommand, zero.TriggerShell:
+			ps = append(ps, pattern{text: prefix + l})
+		case zero.TriggerPrefix:
+			ps = append(ps, pattern{text: l})
+		case zero.TriggerFullMatch:
+			ps = append(ps, pattern{text: l, exact: true})
+		}
+	}
+	return ps
+}
+
+// FindConflicts 检查不同插件注册的消息触发器之间的冲突
+//
+//	命令按当前的 CommandPrefix 展开, 请在 Run 之后或 RunAndBlock 的 preblock 中调用
+func FindConflicts() []Conflict {
+	return findConflicts(zero.ListMatchers(), zero.GetConfig().CommandPrefix)
+}
+
+// samePlugin a 与 b 是否由同一插件注册
+//
+//	未命名的 Engine (如 zero.OnCommand 使用的默认 Engine) 由多个插件共用, 按源文件所在目录区分
+func samePlugin(a, b zero.MatcherInfo) bool {
+	if a.Engine != b.Engine {
+		return false
+	}
+	if a.Service != "" {
+		return true
+	}
+	return a.File != "" && filepath.Dir(a.File) == filepath.Dir(b.File)
+}
+
+// findConflicts infos 需按匹配顺序排列
+func findConflicts(infos []zero.MatcherInfo, prefix string) []Conflict {
+	var conflicts []Conflict
+	for i := range infos {
+		a := infos[i]
+		if a.Temp || a.Replaced || a.Kind == "" {
+			continue
+		}
+		var re *regexp.Regexp
+		if a.Kind == zero.TriggerRegex {
+			re, _ = regexp.Compile(a.Regex)
+		}
+		pa := patterns(a, prefix)
+		for j := i + 1; j < len(infos); j++ {
+			b := infos[j]
+			if b.Temp || b.Replaced || samePlugin(a, b) {
+				continue
+			}
+			add := func(kind, literal string) {
+				conflicts = append(conflicts, Conflict{
+					Kind: kind, Literal: literal, A: a, B: b,
+					SamePriority: a.Priority == b.Priority,
+				})
+			}
+			pb := patterns(b, prefix)
+			if re != nil {
+				for _, p := range pb {
+					if re.MatchString(p.text) {
+						add(ConflictRegex, p.text)
+						break
+					}
+				}
+			}
+			if b.Kind == zero.TriggerRegex {
+				if rb, err := regexp.Compile(b.Regex); err == nil {
+					for _, p := range pa {
+						if rb.MatchString(p.text) {
+							add(ConflictRegex, p.text)
+							break
+						}
+					}
+				}
+			}
+			if kind, literal := overlap(pa, pb); kind != "" {
+				add(kind, literal)
+			}
+		}
+	}
+	return conflicts
+}
+
+// overlap 返回 a 与 b 的字面量之间最严重的冲突
+func overlap(a, b []pattern) (kind, literal string) {
+	for _, x := range a {
+		for _, y := range b {
+			switch {
+			case x.text == y.text && x.exact == y.exact:
+				return ConflictDuplicate, y.text
+			case !x.exact && strings.HasPrefix(y.text, x.text):
+				kind, literal = ConflictPrefix, y.text
+			case !y.exact && strings.HasPrefix(x.text, y.text):
+				kind, literal = ConflictPrefix, x.text
+			}
+		}
+	}
+	return
+}
+
+// CheckConflicts 记录 FindConflicts 发现的冲突, strict 时有冲突返回 ErrConflict
+//
+//	可在 RunAndBlock 的 preblock 中调用, 如
+//
+//	zero.RunAndBlock(&config, func() {
+//		if err := control.CheckConflicts(true); err != nil {
+//			panic(err)
+//		}
+//	})
+func CheckConflicts(strict bool) error {
+	conflicts := FindConflicts()
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].Kind < conflicts[j].Kind
+	})
+	for _, c := range conflicts {
+		logrus.Warnln("[control] 触发冲突", c.String())
+	}
+	if strict && len(conflicts) > 0 {
+		return fmt.Errorf("%w: %d found", ErrConflict, len(conflicts))
+	}
+	return nil
+}
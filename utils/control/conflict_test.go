@@ -0,0 +1,65 @@
+package control
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+)
+
+func TestFindConflicts(t *testing.T) {
+	e1, e2 := zero.New().SetName("a"), zero.New().SetName("b")
+	def := zero.New() // 未命名, 按目录区分插件
+	info := func(e zero.IEngine, kind string, literals ...string) zero.MatcherInfo {
+		return zero.MatcherInfo{Engine: e, Service: e.GetName(), Kind: kind, Literals: literals, Priority: 1, File: "/p/a/a.go"}
+	}
+	regex := info(e2, zero.TriggerRegex)
+	regex.Regex = `^/ban\s+\d+$`
+	other := info(def, zero.TriggerCommand, "help")
+	other.File = "/p/b/b.go"
+	later := info(e2, zero.TriggerCommand, "ping")
+	later.Priority = 2
+
+	for _, c := range []struct {
+		name string
+		a, b zero.MatcherInfo
+		kind string
+		lit  string
+	}{
+		{"duplicate", info(e1, zero.TriggerCommand, "ping"), later, ConflictDuplicate, "/ping"},
+		{"prefix", info(e1, zero.TriggerPrefix, "/pi"), later, ConflictPrefix, "/ping"},
+		{"regex", info(e1, zero.TriggerFullMatch, "/ban 123"), regex, ConflictRegex, "/ban 123"},
+		{"default engine", info(def, zero.TriggerCommand, "help"), other, ConflictDuplicate, "/help"},
+		{"same plugin", info(e1, zero.TriggerCommand, "ping"), info(e1, zero.TriggerCommand, "ping"), "", ""},
+		{"same dir", info(def, zero.TriggerCommand, "help"), info(def, zero.TriggerCommand, "help"), "", ""},
+		{"full match", info(e1, zero.TriggerFullMatch, "/pi"), later, "", ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			conflicts := findConflicts([]zero.MatcherInfo{c.a, c.b}, "/")
+			if c.kind == "" {
+				assert.Empty(t, conflicts)
+				return
+			}
+			if assert.Len(t, conflicts, 1) {
+				assert.Equal(t, c.kind, conflicts[0].Kind)
+				assert.Equal(t, c.lit, conflicts[0].Literal)
+				assert.Equal(t, c.a.Priority == c.b.Priority, conflicts[0].SamePriority)
+			}
+		})
+	}
+}
+
+func TestCheckConflicts(t *testing.T) {
+	m1 := zero.New().SetName("conflict_a").OnCommand("conflict_test")
+	m2 := zero.New().SetName("conflict_b").OnCommand("conflict_test")
+	defer m1.Delete()
+	defer m2.Delete()
+	found := false
+	for _, c := range FindConflicts() {
+		found = found || c.Kind == ConflictDuplicate && c.Literal == zero.GetConfig().CommandPrefix+"conflict_test"
+	}
+	assert.True(t, found)
+	assert.ErrorIs(t, CheckConflicts(true), ErrConflict)
+	assert.NoError(t, CheckConflicts(false))
+}
@@ -39,7 +39,7 @@ func newengine(service string, prio int, o *Options[zero.Context]) IControlEngin
 		panic(fmt.Sprint("prio", prio, "is used by", s))
 	}
 	priomap[prio] = service
-	e.en = zero.New()
+	e.en = zero.New().SetName(service)
 	e.en.UsePreHandler(
 		func(ctx zero.Context) bool {
 			// 防止自触发
@@ -207,3 +207,10 @@ func (e *Engine) OnSuffixGroup(suffix []string, rules ...zero.Rule) IControlMatc
 func (e *Engine) OnShell(command string, model any, rules ...zero.Rule) IControlMatcher {
 	return e.en.OnShell(command, model, rules...).SetPriority(e.prio)
 }
+
+// Command 命令树触发器
+func (e *Engine) Command(name string, rules ...zero.Rule) *zero.CommandNode {
+	c := e.en.Command(name, rules...)
+	c.Matcher().SetPriority(e.prio)
+	return c
+}
@@ -56,6 +56,7 @@ type IControl[CTX any] interface {
 
 // IControlEngineTrigger is an interface for trigger.
 type IControlEngineTrigger interface {
+	Command(name string, rules ...zero.Rule) *zero.CommandNode
 	On(typ string, rules ...zero.Rule) IControlMatcher
 	OnCommand(commands string, rules ...zero.Rule) IControlMatcher
 	OnCommandGroup(commands []string, rules ...zero.Rule) IControlMatcher
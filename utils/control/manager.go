@@ -32,6 +32,11 @@ func (m *Manager[CTX]) DB() *sql.Sqlite {
 	return m.d
 }
 
+// Close 关闭数据库
+func (m *Manager[CTX]) Close() error {
+	return m.d.Close()
+}
+
 // NewManager 打开管理数据库
 func NewManager[CTX any](dbpath string) (m Manager[CTX]) {
 	switch {
@@ -35,6 +35,10 @@ const (
 // managers 每个插件对应的管理
 var managers = NewManager[zero.Context](dbfile)
 
+func init() {
+	zero.OnShutdown(managers.Close) // 关闭时写入数据库
+}
+
 func newctrl(service string, o *Options[zero.Context]) zero.Rule {
 	c := managers.NewControl(service, o)
 	return func(ctx zero.Context) bool {
@@ -104,14 +108,14 @@ func init() {
 		case "响应", "response":
 			err := managers.Response(grp)
 			if err == nil {
-				msg = message.Text(zero.BotConfig.NickName[0], "将开始在此工作啦~")
+				msg = message.Text(zero.GetConfig().NickName[0], "将开始在此工作啦~")
 			} else {
 				msg = message.Text("ERROR: ", err)
 			}
 		case "沉默", "silence":
 			err := managers.Silence(grp)
 			if err == nil {
-				msg = message.Text(zero.BotConfig.NickName[0], "将开始休息啦~")
+				msg = message.Text(zero.GetConfig().NickName[0], "将开始休息啦~")
 			} else {
 				msg = message.Text("ERROR: ", err)
 			}
@@ -130,14 +134,14 @@ func init() {
 		case strings.Contains(cmd, "响应") || strings.Contains(cmd, "response"):
 			err := managers.Response(0)
 			if err == nil {
-				msg = message.Text(zero.BotConfig.NickName[0], "将开始在全部位置工作啦~")
+				msg = message.Text(zero.GetConfig().NickName[0], "将开始在全部位置工作啦~")
 			} else {
 				msg = message.Text("ERROR: ", err)
 			}
 		case strings.Contains(cmd, "沉默") || strings.Contains(cmd, "silence"):
 			err := managers.Silence(0)
 			if err == nil {
-				msg = message.Text(zero.BotConfig.NickName[0], "将开始在未显式启用的位置休息啦~")
+				msg = message.Text(zero.GetConfig().NickName[0], "将开始在未显式启用的位置休息啦~")
 			} else {
 				msg = message.Text("ERROR: ", err)
 			}
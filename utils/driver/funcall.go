@@ -3,6 +3,7 @@ package driver
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"runtime/debug"
@@ -54,6 +55,26 @@ type FCClient struct {
 
 var fccs = make(map[string]*FCClient)
 
+// 注册 zero.LoadConfig 中 type: funcall 的驱动, name 为 NewFuncallClient 中的名称
+func init() {
+	zero.RegisterDriver("funcall", func(c zero.DriverConfig) (zero.Driver, error) {
+		var cfg struct {
+			Name string `json:"name"`
+		}
+		if err := c.Decode(&cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Name == "" {
+			return nil, c.Errorf("name", "required")
+		}
+		fcc, ok := fccs[cfg.Name]
+		if !ok {
+			return nil, c.Errorf("name", "funcall client %q is not created by NewFuncallClient", cfg.Name)
+		}
+		return fcc, nil
+	})
+}
+
 // NewFuncallClient ...
 func NewFuncallClient(name string, newcaller func(CQBot) Caller, init func(*FCClient)) *FCClient {
 	fcc, ok := fccs[name]
@@ -104,6 +125,22 @@ func (f *FCClient) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
 	return *rsp, err
 }
 
+// CallApiContext 发送请求, 函数调用无法中途取消, 仅在调用前检查 ctx
+//
+//nolint:stylecheck,revive
+func (f *FCClient) CallApiContext(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nullResponse, err
+	}
+	return f.CallApi(req)
+}
+
+// Close 从 APICallers 中删除, 函数调用无连接需要断开
+func (f *FCClient) Close() error {
+	zero.APICallers.Delete(f.selfID)
+	return nil
+}
+
 // SelfID 获得 bot qq 号
 func (f *FCClient) SelfID() int64 {
 	return f.selfID
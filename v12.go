@@ -0,0 +1,348 @@
+package zero
+
+import (
+	"container/list"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// OneBot 协议版本
+const (
+	OneBotV11 = 11
+	OneBotV12 = 12
+)
+
+// ProtocolVersioner 由声明 OneBot 协议版本的 APICaller 实现, 未实现时视为 OneBot 11
+type ProtocolVersioner interface {
+	OneBotVersion() int
+}
+
+func isV12Caller(caller APICaller) bool {
+	v, ok := caller.(ProtocolVersioner)
+	return ok && v.OneBotVersion() == OneBotV12
+}
+
+// v12IDCacheSize v12ids 最多记录的非数字 ID 数
+const v12IDCacheSize = 1 << 16
+
+// v12ids 记录非数字 ID 映射后的 int64 到原 ID 的对应
+var v12ids = newIDCache(v12IDCacheSize)
+
+func init() {
+	message.V12UserIDToV11 = func(id string) string {
+		return strconv.FormatInt(ParseV12ID(id), 10)
+	}
+	message.V12UserIDToV12 = func(qq string) string {
+		i, err := strconv.ParseInt(qq, 10, 64)
+		if err != nil {
+			return qq
+		}
+		return FormatV12ID(i)
+	}
+}
+
+// ParseV12ID 将 OneBot 12 的字符串 ID 转换为 int64
+//
+//	非数字 ID 将使用 crc64 映射, 可通过 FormatV12ID 还原;
+//	最多记录最近使用的 65536 个非数字 ID, 更早的将无法还原
+func ParseV12ID(id string) int64 {
+	if id == "" {
+		return 0
+	}
+	if i, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return i
+	}
+	i := crcID(id)
+	v12ids.store(i, id)
+	return i
+}
+
+// FormatV12ID 将 int64 ID 还原为 OneBot 12 的字符串 ID
+func FormatV12ID(id int64) string {
+	if s, ok := v12ids.load(id); ok {
+		return s
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// idCache 按最近使用淘汰的 ID 映射
+type idCache struct {
+	mu  sync.Mutex
+	max int
+	lru *list.List // 最近使用的在前, 元素为 idEntry
+	m   map[int64]*list.Element
+}
+
+type idEntry struct {
+	id int64
+	s  string
+}
+
+func newIDCache(max int) *idCache {
+	return &idCache{max: max, lru: list.New(), m: make(map[int64]*list.Element)}
+}
+
+func (c *idCache) store(id int64, s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.m[id]; ok {
+		e.Value = idEntry{id: id, s: s}
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.m[id] = c.lru.PushFront(idEntry{id: id, s: s})
+	if c.lru.Len() > c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.m, oldest.Value.(idEntry).id)
+	}
+}
+
+func (c *idCache) load(id int64) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.m[id]
+	if !ok {
+		return "", false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(idEntry).s, true
+}
+
+// isV12Event 判断上报是否为 OneBot 12 事件
+func isV12Event(rsp gjson.Result) bool {
+	return !rsp.Get("post_type").Exists() && rsp.Get("type").Exists() && rsp.Get("detail_type").Exists()
+}
+
+// v12NoticeTypes OneBot 12 通知事件对应的 OneBot 11 notice_type
+var v12NoticeTypes = map[string]string{
+	"friend_increase":        "friend_add",
+	"private_message_delete": "friend_recall",
+	"group_member_increase":  "group_increase",
+	"group_member_decrease":  "group_decrease",
+	"group_message_delete":   "group_recall",
+}
+
+// normalizeV12Event 将 OneBot 12 事件转换为 OneBot 11 格式
+// https://12.onebot.dev/connect/data-protocol/event/
+func normalizeV12Event(rsp gjson.Result) ([]byte, *Self) {
+	self := &Self{
+		Platform: rsp.Get("self.platform").Str,
+		UserID:   rsp.Get("self.user_id").Str,
+	}
+	detail := rsp.Get("detail_type").Str
+	e := H{
+		"time":     rsp.Get("time").Int(),
+		"self_id":  ParseV12ID(self.UserID),
+		"sub_type": rsp.Get("sub_type").Str,
+	}
+	for _, k := range [...]string{"user_id", "group_id", "operator_id"} {
+		if v := rsp.Get(k); v.Exists() {
+			e[k] = ParseV12ID(v.String())
+		}
+	}
+	for _, k := range [...]string{"guild_id", "channel_id"} {
+		if v := rsp.Get(k); v.Exists() {
+			e[k] = v.String()
+		}
+	}
+	if v := rsp.Get("message_id"); v.Exists() {
+		if i, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			e["message_id"] = i
+		} else {
+			e["message_id"] = v.String()
+		}
+	}
+	switch rsp.Get("type").Str {
+	case "message":
+		e["post_type"] = "message"
+		e["message_type"] = detail
+		if detail == "channel" { // 与 guild 消息相同处理
+			e["message_type"] = "guild"
+			e["sub_type"] = "channel"
+			e["group_id"] = crcID(rsp.Get("guild_id").String(), rsp.Get("channel_id").String())
+		}
+		msg := message.FromV12(message.ParseMessageFromArray(rsp.Get("message")))
+		e["message"] = msg
+		e["raw_message"] = msg.String()
+		e["sender"] = H{"user_id": e["user_id"]}
+	case "notice":
+		e["post_type"] = "notice"
+		e["notice_type"] = detail
+		if t, ok := v12NoticeTypes[detail]; ok {
+			e["notice_type"] = t
+		}
+	case "request":
+		e["post_type"] = "request"
+		e["request_type"] = detail
+	case "meta":
+		e["post_type"] = "meta_event"
+		e["meta_event_type"] = detail
+		if detail == "connect" {
+			e["meta_event_type"] = "lifecycle"
+			e["sub_type"] = "connect"
+		}
+	default:
+		e["post_type"] = rsp.Get("type").Str
+	}
+	b, _ := json.Marshal(e)
+	return b, self
+}
+
+// v12Action OneBot 11 API 对应的 OneBot 12 动作
+type v12Action struct {
+	action     string
+	detailType string
+	params     []string
+}
+
+// v12Actions OneBot 11 API 到 OneBot 12 动作的映射
+// https://12.onebot.dev/interface/
+var v12Actions = map[string]v12Action{
+	"send_msg":               {action: "send_message", params: []string{"user_id", "group_id", "message"}},
+	"send_private_msg":       {action: "send_message", detailType: "private", params: []string{"user_id", "message"}},
+	"send_group_msg":         {action: "send_message", detailType: "group", params: []string{"group_id", "message"}},
+	"send_guild_channel_msg": {action: "send_message", detailType: "channel", params: []string{"guild_id", "channel_id", "message"}},
+	"delete_msg":             {action: "delete_message", params: []string{"message_id"}},
+	"get_login_info":         {action: "get_self_info"},
+	"get_stranger_info":      {action: "get_user_info", params: []string{"user_id"}},
+	"get_friend_list":        {action: "get_friend_list"},
+	"get_group_info":         {action: "get_group_info", params: []string{"group_id"}},
+	"get_group_list":         {action: "get_group_list"},
+	"get_group_member_info":  {action: "get_group_member_info", params: []string{"group_id", "user_id"}},
+	"get_group_member_list":  {action: "get_group_member_list", params: []string{"group_id"}},
+	"set_group_name":         {action: "set_group_name", params: []string{"group_id", "group_name"}},
+	"set_group_leave":        {action: "leave_group", params: []string{"group_id"}},
+	"get_version_info":       {action: "get_version"},
+	"get_status":             {action: "get_status"},
+}
+
+// toV12Request 将 OneBot 11 API 请求映射为 OneBot 12 动作请求
+//
+//	无对应动作时返回 false, 请求原样发送
+func toV12Request(req APIRequest) (APIRequest, bool) {
+	a, ok := v12Actions[req.Action]
+	if !ok {
+		return req, false
+	}
+	params := make(Params, len(a.params)+1)
+	for _, k := range a.params {
+		v, ok := req.Params[k]
+		if !ok {
+			continue
+		}
+		switch k {
+		case "user_id", "group_id":
+			params[k] = v12IDParam(v)
+		case "message":
+			params[k] = v12MessageParam(v)
+		default:
+			params[k] = v
+		}
+	}
+	switch {
+	case a.detailType != "":
+		params["detail_type"] = a.detailType
+	case req.Action == "send_msg":
+		params["detail_type"] = "private"
+		if _, ok := req.Params["group_id"]; ok {
+			params["detail_type"] = "group"
+			delete(params, "user_id")
+		}
+	}
+	return APIRequest{
+		Action: a.action,
+		Params: params,
+		Echo:   req.Echo,
+		Self:   req.Self,
+	}, true
+}
+
+func v12IDParam(v interface{}) interface{} {
+	switch id := v.(type) {
+	case int64:
+		return FormatV12ID(id)
+	case int:
+		return FormatV12ID(int64(id))
+	default:
+		return v
+	}
+}
+
+func v12MessageParam(v interface{}) interface{} {
+	switch m := v.(type) {
+	case string:
+		return message.ToV12(message.ParseMessageFromString(m))
+	case message.Message:
+		return message.ToV12(m)
+	case *message.Message:
+		return message.ToV12(*m)
+	case []message.MessageSegment:
+		return message.ToV12(m)
+	case message.MessageSegment:
+		return message.ToV12(message.Message{m})
+	default:
+		return v
+	}
+}
+
+// v12DataKeys OneBot 12 响应字段对应的 OneBot 11 字段
+var v12DataKeys = map[string]string{
+	"user_name":        "nickname",
+	"user_displayname": "card",
+	"impl":             "app_name",
+	"version":          "app_version",
+	"onebot_version":   "protocol_version",
+}
+
+// fromV12Data 将 OneBot 12 动作响应数据转换为 OneBot 11 格式
+func fromV12Data(data gjson.Result) gjson.Result {
+	if !data.IsObject() && !data.IsArray() {
+		return data
+	}
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(data.Raw))
+	dec.UseNumber()
+	if dec.Decode(&v) != nil {
+		return data
+	}
+	b, err := json.Marshal(fromV12Value(v))
+	if err != nil {
+		return data
+	}
+	return gjson.ParseBytes(b)
+}
+
+func fromV12Value(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			switch k {
+			case "user_id", "group_id", "self_id", "operator_id":
+				if s, ok := val.(string); ok {
+					m[k] = ParseV12ID(s)
+					continue
+				}
+			}
+			if nk, ok := v12DataKeys[k]; ok {
+				k = nk
+			}
+			m[k] = fromV12Value(val)
+		}
+		return m
+	case []interface{}:
+		for i := range x {
+			x[i] = fromV12Value(x[i])
+		}
+		return x
+	default:
+		return v
+	}
+}
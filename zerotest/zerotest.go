@@ -0,0 +1,296 @@
+// Package zerotest 提供内存中的 OneBot 实现, 用于测试插件
+//
+//	bot := zerotest.New(123456)
+//	bot.Run(zero.Config{CommandPrefix: "/"})
+//	defer bot.Close()
+//	bot.SendGroupText(1, 2, "/ping")
+//	bot.ExpectReply(t, "pong")
+package zerotest
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+// DefaultTimeout ExpectReply 等待回复的默认时长
+var DefaultTimeout = time.Second
+
+// Responder 根据请求生成响应
+type Responder func(req zero.APIRequest) zero.APIResponse
+
+// Reply 机器人发送的一条消息
+type Reply struct {
+	Request zero.APIRequest
+	Message message.Message // 合并转发时为所有节点
+}
+
+// Bot 内存中的 OneBot 实现, 同时实现 zero.Driver 与 zero.APICaller
+type Bot struct {
+	SelfID int64
+
+	handler   func([]byte, zero.APICaller)
+	ready     chan struct{}
+	readyOnce sync.Once
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu         sync.Mutex
+	requests   []zero.APIRequest
+	responders map[string]Responder
+	replies    chan Reply
+	msgid      int64
+}
+
+// New 创建账号为 selfID 的 Bot
+func New(selfID int64) *Bot {
+	return &Bot{
+		SelfID:     selfID,
+		ready:      make(chan struct{}),
+		done:       make(chan struct{}),
+		responders: make(map[string]Responder),
+		replies:    make(chan Reply, 1024),
+	}
+}
+
+// Run 以 Bot 作为唯一的 Driver 调用 zero.Run, 返回时已可注入事件
+func (b *Bot) Run(c zero.Config) {
+	c.Driver = []zero.Driver{b}
+	zero.Run(&c)
+	<-b.ready
+}
+
+// Connect 添加到 zero.APICallers
+func (b *Bot) Connect() {
+	zero.APICallers.Store(b.SelfID, b)
+}
+
+// Listen 保存事件处理函数并阻塞至 Close
+func (b *Bot) Listen(handler func([]byte, zero.APICaller)) {
+	b.handler = handler
+	b.readyOnce.Do(func() { close(b.ready) })
+	<-b.done
+}
+
+// Close 从 zero.APICallers 中删除, Listen 将返回
+func (b *Bot) Close() error {
+	b.closeOnce.Do(func() {
+		zero.APICallers.Delete(b.SelfID)
+		close(b.done)
+	})
+	return nil
+}
+
+// Handle 设置 action 的响应, 未设置的 action 发送消息时返回递增的 message_id, 其余返回空数据
+func (b *Bot) Handle(action string, fn Responder) {
+	b.mu.Lock()
+	b.responders[action] = fn
+	b.mu.Unlock()
+}
+
+// Respond 设置 action 固定返回 data, data 将被序列化为 JSON
+func (b *Bot) Respond(action string, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	result := gjson.ParseBytes(raw)
+	b.Handle(action, func(zero.APIRequest) zero.APIResponse {
+		return zero.APIResponse{Status: "ok", Data: result}
+	})
+}
+
+// Requests 返回已收到的所有 API 请求
+func (b *Bot) Requests() []zero.APIRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]zero.APIRequest(nil), b.requests...)
+}
+
+// CallApi 记录请求并返回设置的响应
+func (b *Bot) CallApi(req zero.APIRequest) (zero.APIResponse, error) {
+	b.mu.Lock()
+	b.requests = append(b.requests, req)
+	fn, scripted := b.responders[req.Action]
+	b.mu.Unlock()
+	var rsp zero.APIResponse
+	if scripted {
+		rsp = fn(req)
+	} else {
+		rsp = zero.APIResponse{Status: "ok", Data: gjson.Parse("null")}
+	}
+	if m, ok := replyMessage(req); ok {
+		if !scripted || !rsp.Data.Get("message_id").Exists() {
+			id := atomic.AddInt64(&b.msgid, 1)
+			rsp.Data = gjson.Parse(`{"message_id":` + strconv.FormatInt(id, 10) + `}`)
+		}
+		select {
+		case b.replies <- Reply{Request: req, Message: m}:
+		default: // 回复过多未读取时丢弃
+		}
+	}
+	rsp.Echo = req.Echo
+	return rsp, nil
+}
+
+// CallApiContext 同 CallApi
+func (b *Bot) CallApiContext(ctx context.Context, req zero.APIRequest) (zero.APIResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return zero.APIResponse{}, err
+	}
+	return b.CallApi(req)
+}
+
+// replyMessage 获取发送消息请求中的消息
+func replyMessage(req zero.APIRequest) (message.Message, bool) {
+	switch req.Action {
+	case "send_msg", "send_group_msg", "send_private_msg", "send_guild_channel_msg":
+		return toMessage(req.Params["message"]), true
+	case "send_group_forward_msg", "send_private_forward_msg":
+		return toMessage(req.Params["messages"]), true
+	}
+	return nil, false
+}
+
+func toMessage(v interface{}) message.Message {
+	switch m := v.(type) {
+	case message.Message:
+		return m
+	case *message.Message:
+		return *m
+	case []message.MessageSegment:
+		return m
+	case message.MessageSegment:
+		return message.Message{m}
+	case string:
+		return message.ParseMessageFromString(m)
+	default:
+		raw, _ := json.Marshal(v)
+		return message.ParseMessage(raw)
+	}
+}
+
+// Inject 注入 OneBot 11 格式的事件, 未设置的 time self_id 将自动填充
+func (b *Bot) Inject(event zero.H) {
+	if _, ok := event["time"]; !ok {
+		event["time"] = time.Now().Unix()
+	}
+	if _, ok := event["self_id"]; !ok {
+		event["self_id"] = b.SelfID
+	}
+	raw, err := json.Marshal(event)
+	if err != nil {
+		panic(err)
+	}
+	b.InjectRaw(raw)
+}
+
+// InjectRaw 注入原始事件 JSON
+func (b *Bot) InjectRaw(raw []byte) {
+	<-b.ready
+	b.handler(raw, b)
+}
+
+// GroupMessage 构造群消息事件, 可修改后通过 Inject 注入
+//
+//	text 为 CQ 码字符串, 发送者为普通成员
+func (b *Bot) GroupMessage(gid, uid int64, text string) zero.H {
+	return zero.H{
+		"post_type":    "message",
+		"message_type": "group",
+		"sub_type":     "normal",
+		"message_id":   atomic.AddInt64(&b.msgid, 1),
+		"group_id":     gid,
+		"user_id":      uid,
+		"message":      text,
+		"raw_message":  text,
+		"sender": zero.H{
+			"user_id":  uid,
+			"nickname": "user" + strconv.FormatInt(uid, 10),
+			"role":     "member",
+		},
+	}
+}
+
+// PrivateMessage 构造私聊消息事件, 可修改后通过 Inject 注入
+func (b *Bot) PrivateMessage(uid int64, text string) zero.H {
+	return zero.H{
+		"post_type":    "message",
+		"message_type": "private",
+		"sub_type":     "friend",
+		"message_id":   atomic.AddInt64(&b.msgid, 1),
+		"user_id":      uid,
+		"message":      text,
+		"raw_message":  text,
+		"sender": zero.H{
+			"user_id":  uid,
+			"nickname": "user" + strconv.FormatInt(uid, 10),
+		},
+	}
+}
+
+// SendGroupText 以 uid 的身份在群 gid 发送 text
+func (b *Bot) SendGroupText(gid, uid int64, text string) {
+	b.Inject(b.GroupMessage(gid, uid, text))
+}
+
+// SendPrivateText 以 uid 的身份私聊发送 text
+func (b *Bot) SendPrivateText(uid int64, text string) {
+	b.Inject(b.PrivateMessage(uid, text))
+}
+
+// NextReply 等待机器人发送的下一条消息, 超时返回 false
+func (b *Bot) NextReply(timeout time.Duration) (Reply, bool) {
+	select {
+	case r := <-b.replies:
+		return r, true
+	case <-time.After(timeout):
+		return Reply{}, false
+	}
+}
+
+// ExpectReply 等待 DefaultTimeout, 断言机器人发送的下一条消息的纯文本为 want
+func (b *Bot) ExpectReply(t testing.TB, want string) Reply {
+	t.Helper()
+	r, ok := b.NextReply(DefaultTimeout)
+	if !ok {
+		t.Fatalf("zerotest: expected reply %q, got none in %v", want, DefaultTimeout)
+		return r
+	}
+	if got := PlainText(r.Message); got != want {
+		t.Errorf("zerotest: expected reply %q, got %q (%s)", want, got, r.Message.CQCode())
+	}
+	return r
+}
+
+// ExpectNoReply 断言在 d 内机器人未发送消息
+func (b *Bot) ExpectNoReply(t testing.TB, d time.Duration) {
+	t.Helper()
+	if r, ok := b.NextReply(d); ok {
+		t.Errorf("zerotest: expected no reply, got %s", r.Message.CQCode())
+	}
+}
+
+// PlainText 消息中所有纯文本段的拼接, 合并转发节点取其内容
+func PlainText(m message.Message) string {
+	var sb strings.Builder
+	for _, seg := range m {
+		switch seg.Type {
+		case "text":
+			sb.WriteString(seg.Data["text"])
+		case "node":
+			sb.WriteString(PlainText(message.ParseMessageFromString(seg.Data["content"])))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,104 @@
+package zerotest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	zero "github.com/wdvxdr1123/ZeroBot"
+	"github.com/wdvxdr1123/ZeroBot/message"
+)
+
+func TestBot(t *testing.T) {
+	zero.OnCommand("zerotest_ping").SetBlock(true).Handle(func(ctx zero.Context) {
+		ctx.Send("pong")
+	})
+	zero.OnFullMatch("zerotest_info").SetBlock(true).Handle(func(ctx zero.Context) {
+		info, err := ctx.API().GetGroupInfo(1, false)
+		if err != nil {
+			ctx.Send(err.Error())
+			return
+		}
+		ctx.Send(info.Name)
+	})
+
+	bot := New(123456)
+	bot.Respond("get_group_info", zero.H{"group_id": 1, "group_name": "test"})
+	bot.Run(zero.Config{CommandPrefix: "/"})
+	defer bot.Close()
+
+	bot.SendGroupText(1, 2, "/zerotest_ping")
+	r := bot.ExpectReply(t, "pong")
+	assert.Equal(t, "send_group_msg", r.Request.Action)
+
+	bot.SendPrivateText(2, "zerotest_info")
+	bot.ExpectReply(t, "test")
+
+	bot.SendGroupText(1, 2, "nothing")
+	bot.ExpectNoReply(t, 100*time.Millisecond)
+
+	var actions []string
+	for _, req := range bot.Requests() {
+		actions = append(actions, req.Action)
+	}
+	assert.Contains(t, actions, "get_group_info")
+}
+
+func TestSendOverLength(t *testing.T) {
+	ids := make(chan message.MessageID, 1)
+	m := zero.OnFullMatch("zerotest_long").SetBlock(true).Handle(func(ctx zero.Context) {
+		ids <- ctx.Send("12345678901")
+	})
+	defer m.Delete()
+
+	bot := New(123457)
+	cfg := zero.Config{MaxMessageLen: 5}
+	bot.Run(cfg)
+	defer bot.Close()
+
+	// SplitOverLength: 拆分为多条消息, 返回第一条的 ID
+	bot.SendGroupText(1, 2, "zerotest_long")
+	first := bot.ExpectReply(t, "12345")
+	bot.ExpectReply(t, "67890")
+	bot.ExpectReply(t, "1")
+	assert.Equal(t, "send_group_msg", first.Request.Action)
+	id := <-ids
+	assert.Equal(t, int64(2), id.ID()) // 事件的 message_id 为 1
+	bot.ExpectNoReply(t, 50*time.Millisecond)
+
+	// ForwardOverLength: 拆分后作为一条合并转发发送
+	cfg.LengthPolicy = zero.ForwardOverLength
+	assert.NoError(t, zero.UpdateConfig(&cfg))
+	bot.SendGroupText(1, 2, "zerotest_long")
+	r := bot.ExpectReply(t, "12345678901")
+	assert.Equal(t, "send_group_forward_msg", r.Request.Action)
+	assert.Len(t, r.Message, 3)
+	assert.Equal(t, int64(6), (<-ids).ID()) // 事件为 5, 三条拆分消息为 2 3 4
+	bot.ExpectNoReply(t, 50*time.Millisecond)
+}
+
+func TestShellUsage(t *testing.T) {
+	type args struct {
+		N int `flag:"n" required:"true" help:"次数"`
+	}
+	m1 := zero.OnShell("zerotest_shell", args{}).SetBlock(true).Handle(func(ctx zero.Context) {
+		ctx.Send("ok")
+	})
+	defer m1.Delete()
+	m2 := zero.OnMessage(zero.ShellRule("zerotest_rule", args{})).SetBlock(true).Handle(func(ctx zero.Context) {
+		ctx.Send("ok")
+	})
+	defer m2.Delete()
+
+	bot := New(123458)
+	bot.Run(zero.Config{CommandPrefix: "/"})
+	defer bot.Close()
+
+	for _, cmd := range []string{"zerotest_shell", "zerotest_rule"} { // OnShell 与 ShellRule 均回复用法
+		bot.SendGroupText(1, 2, "/"+cmd)
+		bot.ExpectReply(t, "缺少必需的选项 -n\n用法: /"+cmd+" [选项]\n  -n int  次数")
+		bot.SendGroupText(1, 2, "/"+cmd+" -n 1")
+		bot.ExpectReply(t, "ok")
+	}
+}